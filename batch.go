@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Whether err is an X error for a window that no longer exists, windows can close between being listed and being moved
+func isTransientWindowError(err error) bool {
+	var window_error xproto.WindowError
+	var drawable_error xproto.DrawableError
+	return errors.As(err, &window_error) || errors.As(err, &drawable_error)
+}
+
+// Managed client windows bottom of the stack first, only those on the current desktop unless allDesktops is set
+func enumerateWindows(X *xgbutil.XUtil, allDesktops bool) ([]xproto.Window, error) {
+	stacking, err := ewmh.ClientListStackingGet(X)
+	if err != nil {
+		return nil, err
+	}
+	current := func() (uint, error) {
+		return ewmh.CurrentDesktopGet(X)
+	}
+	windowDesktop := func(win xproto.Window) (uint, error) {
+		return ewmh.WmDesktopGet(X, win)
+	}
+	return desktopWindows(stacking, allDesktops, current, windowDesktop), nil
+}
+
+// The windows enumerateWindows includes, desktops are only looked up when not including every desktop
+func desktopWindows(windows []xproto.Window, allDesktops bool, current func() (uint, error), windowDesktop func(xproto.Window) (uint, error)) []xproto.Window {
+	if allDesktops {
+		return windows
+	}
+
+	desktop, err := current()
+	if err != nil {
+		// No desktops, every window is on the current one
+		return windows
+	}
+	return onDesktop(windows, desktop, windowDesktop)
+}
+
+// Filters windows down to those shown on desktop, windows without a desktop are shown everywhere
+func onDesktop(windows []xproto.Window, desktop uint, windowDesktop func(xproto.Window) (uint, error)) []xproto.Window {
+	shown := make([]xproto.Window, 0, len(windows))
+	for _, win := range windows {
+		d, err := windowDesktop(win)
+		if err != nil || d == desktop || d == allDesktops {
+			shown = append(shown, win)
+		}
+	}
+	return shown
+}
+
+// Managed client windows mostly on screens[index], leaving out docks, the desktop and splash screens, and sticky
+// windows which belong everywhere
+func windowsOnScreen(X *xgbutil.XUtil, screens []xrect.Rect, index int, allDesktops bool) ([]xproto.Window, error) {
+	clients, err := enumerateWindows(X, allDesktops)
+	if err != nil {
+		return nil, err
+	}
+	return clientsOnScreen(clients, screens, index, func(client xproto.Window) (clientInfo, error) {
+		return readClientInfo(X, client)
+	})
+}
+
+// What the batch modes look at to decide whether to move a client window
+type clientInfo struct {
+	types []string
+	state []string
+	geo   xrect.Rect
+}
+
+func readClientInfo(X *xgbutil.XUtil, client xproto.Window) (clientInfo, error) {
+	// Missing properties just mean no type or state has been set
+	types, _ := ewmh.WmWindowTypeGet(X, client)
+	state, _ := ewmh.WmStateGet(X, client)
+	geo, err := xwindow.New(X, client).DecorGeometry()
+	return clientInfo{types: types, state: state, geo: geo}, err
+}
+
+// Filters clients down to the movable windows mostly on screens[index], keeping their order
+func clientsOnScreen(clients []xproto.Window, screens []xrect.Rect, index int, info func(xproto.Window) (clientInfo, error)) ([]xproto.Window, error) {
+	on_screen := make([]xproto.Window, 0, len(clients))
+	for _, client := range clients {
+		c, err := info(client)
+		if isTransientWindowError(err) {
+			debug.Printf("Skipping window %d, it has closed", client)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !isMovableType(c.types) || isSticky(c.state) {
+			debug.Printf("Skipping %v or sticky window %d", c.types, client)
+			continue
+		}
+		if xrect.LargestOverlap(c.geo, screens) == index {
+			on_screen = append(on_screen, client)
+		}
+	}
+	return on_screen, nil
+}
+
+// Moves a single window from whichever monitor it is on to the target given by opts, notMoved if it was skipped or
+// is already where it would go
+func moveOne(X *xgbutil.XUtil, opts options, win *xwindow.Window, screens []xrect.Rect) (moveResult, error) {
+	opts, skip, err := prepareMove(X, opts, win)
+	if err != nil {
+		return notMoved, err
+	}
+	if skip != "" {
+		log.Printf("Skipping window %d, %s", win.Id, skip)
+		return notMoved, nil
+	}
+
+	geo, err := win.DecorGeometry()
+	if err != nil {
+		return notMoved, fmt.Errorf("error getting window geometry: %w", err)
+	}
+
+	index := resolveSourceIndex(geo, screens)
+	if index == -1 {
+		return notMoved, fmt.Errorf("no monitors")
+	}
+
+	next_index, err := targetScreen(X, opts, screens, index, geo)
+	if err != nil {
+		return notMoved, err
+	}
+	if next_index == index {
+		debug.Printf("No monitor found to move window %d to, leaving it on monitor %d", win.Id, index)
+		return notMoved, nil
+	}
+
+	err = moveToScreen(X, opts, win, geo, screens, index, next_index)
+	if err != nil {
+		return notMoved, err
+	}
+	return moved, nil
+}
+
+// Moves every window on screens[index] to the target given by opts, bottom of the stack first so stacking order is kept
+func moveAll(X *xgbutil.XUtil, opts options, screens []xrect.Rect, index int) (moveResult, error) {
+	windows, err := windowsOnScreen(X, screens, index, opts.allDesktops)
+	if err != nil {
+		return notMoved, err
+	}
+
+	return moveEach(windows, func(id xproto.Window) (moveResult, error) {
+		return moveOne(X, opts, xwindow.New(X, id), screens)
+	})
+}
+
+// Moves every window on screens[src] to screens[dst], carrying on past windows that fail to move
+func evacuate(X *xgbutil.XUtil, opts options, screens []xrect.Rect, src, dst int) (moveResult, error) {
+	windows, err := windowsOnScreen(X, screens, src, opts.allDesktops)
+	if err != nil {
+		return notMoved, err
+	}
+
+	return moveEach(windows, func(id xproto.Window) (moveResult, error) {
+		win := xwindow.New(X, id)
+		geo, err := win.DecorGeometry()
+		if err != nil {
+			return notMoved, err
+		}
+		err = moveToScreen(X, opts, win, geo, screens, src, dst)
+		if err != nil {
+			return notMoved, err
+		}
+		return moved, nil
+	})
+}
+
+// Calls move for each window, carrying on past windows that fail to move. The batch moved if any window did, and
+// failed if every window failed. Windows that closed before they could be moved are skipped rather than failures
+func moveEach(windows []xproto.Window, move func(xproto.Window) (moveResult, error)) (moveResult, error) {
+	moved_count, failed := 0, 0
+	for _, id := range windows {
+		result, err := move(id)
+		switch {
+		case isTransientWindowError(err):
+			log.Printf("Skipping window %d, it has closed", id)
+		case err != nil:
+			log.Printf("Unable to move window %d: %v", id, err)
+			failed++
+		case result == moved:
+			moved_count++
+		}
+	}
+
+	if moved_count > 0 {
+		return moved, nil
+	}
+	if failed > 0 && failed == len(windows) {
+		return notMoved, fmt.Errorf("all %d windows failed to move", failed)
+	}
+	return notMoved, nil
+}
+
+// Reads window ids, one per line, skipping blank lines.
+// Malformed lines are reported in the error but don't stop the rest of the ids from being read
+func readWindowIDs(r io.Reader) ([]xproto.Window, error) {
+	var ids []xproto.Window
+	var malformed []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		id, err := parseWindowID(line)
+		if err != nil {
+			malformed = append(malformed, line)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if err := scanner.Err(); err != nil {
+		return ids, err
+	}
+
+	if len(malformed) > 0 {
+		return ids, fmt.Errorf("invalid window ids: %s", strings.Join(malformed, ", "))
+	}
+	return ids, nil
+}
+
+// The window at the top of the stacking order on screens[index], ok is false if there are no windows there
+func topWindowOnScreen(X *xgbutil.XUtil, screens []xrect.Rect, index int) (win xproto.Window, ok bool) {
+	return topWindow(windowsOnScreen(X, screens, index, false))
+}
+
+// Topmost of windows listed bottom to top, like _NET_CLIENT_LIST_STACKING, ok is false if there are none
+func topWindow(windows []xproto.Window, err error) (win xproto.Window, ok bool) {
+	if err != nil || len(windows) == 0 {
+		return 0, false
+	}
+	return windows[len(windows)-1], true
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+func sideBySide() []xrect.Rect {
+	return []xrect.Rect{
+		xrect.New(0, 0, 1920, 1080),
+		xrect.New(1920, 0, 1920, 1080),
+	}
+}
+
+// Client info looked up from a map instead of the X server, windows not in it have closed
+func stubClients(clients map[xproto.Window]clientInfo) func(xproto.Window) (clientInfo, error) {
+	return func(win xproto.Window) (clientInfo, error) {
+		c, ok := clients[win]
+		if !ok {
+			return clientInfo{}, xproto.WindowError{NiceName: "Window", BadValue: uint32(win)}
+		}
+		return c, nil
+	}
+}
+
+func TestClientsOnScreen(t *testing.T) {
+	left := xrect.New(100, 100, 800, 600)
+	right := xrect.New(2000, 100, 800, 600)
+	clients := map[xproto.Window]clientInfo{
+		1: {geo: left},
+		2: {types: []string{"_NET_WM_WINDOW_TYPE_DOCK"}, geo: left},
+		3: {types: []string{"_NET_WM_WINDOW_TYPE_DESKTOP"}, geo: xrect.New(0, 0, 3840, 1080)},
+		4: {types: []string{"_NET_WM_WINDOW_TYPE_SPLASH"}, geo: left},
+		5: {state: []string{"_NET_WM_STATE_STICKY"}, geo: left},
+		6: {geo: right},
+		8: {types: []string{"_NET_WM_WINDOW_TYPE_DIALOG"}, geo: left},
+		9: {state: []string{"_NET_WM_STATE_MAXIMIZED_HORZ"}, geo: xrect.New(0, 0, 1920, 1080)},
+	}
+	// Window 7 closed after the client list was read
+	list := []xproto.Window{1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	tests := []struct {
+		index int
+		want  []xproto.Window
+	}{
+		{0, []xproto.Window{1, 8, 9}},
+		{1, []xproto.Window{6}},
+	}
+	for _, tt := range tests {
+		got, err := clientsOnScreen(list, sideBySide(), tt.index, stubClients(clients))
+		if err != nil {
+			t.Fatalf("clientsOnScreen(%d) error: %v", tt.index, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("clientsOnScreen(%d) = %v, want %v", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestClientsOnScreenError(t *testing.T) {
+	failure := errors.New("connection lost")
+	_, err := clientsOnScreen([]xproto.Window{1}, sideBySide(), 0, func(xproto.Window) (clientInfo, error) {
+		return clientInfo{}, failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("clientsOnScreen error = %v, want %v", err, failure)
+	}
+}
+
+func TestIsTransientWindowError(t *testing.T) {
+	bad_window := xproto.WindowError{NiceName: "Window", BadValue: 0x1200007}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"BadWindow", bad_window, true},
+		{"BadDrawable", xproto.DrawableError{NiceName: "Drawable", BadValue: 0x1200007}, true},
+		// As wrapped on the way out of moveToScreen
+		{"wrapped BadWindow", fmt.Errorf("unable to move window: %w",
+			fmt.Errorf("unable to retrieve window's state: %w", bad_window)), true},
+		{"BadMatch", xproto.MatchError{NiceName: "Match"}, false},
+		{"generic error", errors.New("no monitors"), false},
+		{"flattened BadWindow", fmt.Errorf("unable to move window: %v", bad_window), false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isTransientWindowError(tt.err); got != tt.want {
+			t.Errorf("%s: isTransientWindowError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClientsOnScreenKeepsStackingOrder(t *testing.T) {
+	left := xrect.New(100, 100, 800, 600)
+	clients := map[xproto.Window]clientInfo{1: {geo: left}, 2: {geo: left}, 3: {geo: left}}
+	for _, list := range [][]xproto.Window{{1, 2, 3}, {3, 1, 2}} {
+		got, err := clientsOnScreen(list, sideBySide(), 0, stubClients(clients))
+		if err != nil {
+			t.Fatalf("clientsOnScreen(%v) error: %v", list, err)
+		}
+		if !reflect.DeepEqual(got, list) {
+			t.Errorf("clientsOnScreen(%v) = %v, want the stacking order kept", list, got)
+		}
+	}
+}
+
+func TestReadWindowIDs(t *testing.T) {
+	input := "0x1c00007\n\n  29360136  \nnot-a-window\n0x3a00004\n0xzz\n"
+	ids, err := readWindowIDs(strings.NewReader(input))
+	want := []xproto.Window{0x1c00007, 29360136, 0x3a00004}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("readWindowIDs = %v, want %v", ids, want)
+	}
+	// The well formed ids are still returned along with the malformed ones
+	if err == nil || !strings.Contains(err.Error(), "not-a-window") || !strings.Contains(err.Error(), "0xzz") {
+		t.Errorf("readWindowIDs error = %v, want the malformed ids listed", err)
+	}
+
+	ids, err = readWindowIDs(strings.NewReader("\n\n"))
+	if err != nil || len(ids) != 0 {
+		t.Errorf("readWindowIDs of blank lines = %v, %v, want nothing", ids, err)
+	}
+}
+
+func TestTopWindowOnScreen(t *testing.T) {
+	left := xrect.New(100, 100, 800, 600)
+	clients := map[xproto.Window]clientInfo{
+		1: {geo: left},
+		2: {geo: left},
+		3: {geo: xrect.New(2000, 100, 800, 600)},
+		// Panels are often on top but are never swapped
+		4: {types: []string{"_NET_WM_WINDOW_TYPE_DOCK"}, geo: left},
+	}
+	// Stacking order, bottom to top
+	stacking := []xproto.Window{2, 1, 3, 4}
+
+	tests := []struct {
+		index  int
+		want   xproto.Window
+		wantOk bool
+	}{
+		{0, 1, true},
+		{1, 3, true},
+	}
+	for _, tt := range tests {
+		got, ok := topWindow(clientsOnScreen(stacking, sideBySide(), tt.index, stubClients(clients)))
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("top window on monitor %d = %d %v, want %d %v", tt.index, got, ok, tt.want, tt.wantOk)
+		}
+	}
+
+	// An empty monitor has nothing to swap with
+	if got, ok := topWindow(clientsOnScreen([]xproto.Window{3}, sideBySide(), 0, stubClients(clients))); ok {
+		t.Errorf("top window on an empty monitor = %d, want none", got)
+	}
+	if got, ok := topWindow([]xproto.Window{1}, errors.New("connection lost")); ok {
+		t.Errorf("top window after an error = %d, want none", got)
+	}
+}
+
+func TestDesktopWindows(t *testing.T) {
+	// _NET_WM_DESKTOP of each window, window 4 is sticky and window 5 doesn't set it
+	desktops := map[xproto.Window]uint{1: 0, 2: 1, 3: 0, 4: allDesktops}
+	windowDesktop := func(win xproto.Window) (uint, error) {
+		d, ok := desktops[win]
+		if !ok {
+			return 0, errors.New("property _NET_WM_DESKTOP not set")
+		}
+		return d, nil
+	}
+	onFirst := func() (uint, error) { return 0, nil }
+	noDesktops := func() (uint, error) { return 0, errors.New("property _NET_CURRENT_DESKTOP not set") }
+	stacking := []xproto.Window{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name        string
+		allDesktops bool
+		current     func() (uint, error)
+		want        []xproto.Window
+	}{
+		{"current desktop", false, onFirst, []xproto.Window{1, 3, 4, 5}},
+		{"-include-all-desktops", true, onFirst, stacking},
+		{"no desktops", false, noDesktops, stacking},
+	}
+	for _, tt := range tests {
+		got := desktopWindows(stacking, tt.allDesktops, tt.current, windowDesktop)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: desktopWindows = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMoveEach(t *testing.T) {
+	closed := xproto.WindowError{NiceName: "Window", BadValue: 3}
+	// Outcome of moving each window
+	outcomes := map[xproto.Window]error{1: nil, 2: errors.New("BadMatch"), 3: closed}
+	skipped := map[xproto.Window]bool{4: true}
+	move := func(id xproto.Window) (moveResult, error) {
+		if skipped[id] {
+			return notMoved, nil
+		}
+		if err := outcomes[id]; err != nil {
+			return notMoved, err
+		}
+		return moved, nil
+	}
+
+	tests := []struct {
+		name    string
+		windows []xproto.Window
+		want    moveResult
+		wantErr bool
+	}{
+		{"some moved", []xproto.Window{1, 2, 3}, moved, false},
+		{"empty monitor", nil, notMoved, false},
+		{"all skipped", []xproto.Window{4}, notMoved, false},
+		{"closed and skipped", []xproto.Window{3, 4}, notMoved, false},
+		{"all failed", []xproto.Window{2, 2}, notMoved, true},
+		{"failed and skipped", []xproto.Window{2, 4}, notMoved, false},
+	}
+	for _, tt := range tests {
+		got, err := moveEach(tt.windows, move)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: moveEach error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: moveEach = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
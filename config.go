@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Flag defaults read from a config file, keyed by flag name.
+//
+// The file is a flat subset of TOML, one key = value per line, e.g.
+//
+//	direction = "East"
+//	wrap = "horizontal"
+//	gap = 8
+//	respect-struts = true
+type Config struct {
+	Defaults map[string]string
+}
+
+// Config file used when -config isn't given
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "go-to-monitor", "config.toml")
+}
+
+// Reads a config file, a missing file is an empty config
+func loadConfig(path string) (Config, error) {
+	config := Config{Defaults: make(map[string]string)}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return config, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			value, err = strconv.Unquote(value)
+			if err != nil {
+				return config, fmt.Errorf("%s:%d: invalid string for %s", path, n, key)
+			}
+		} else if i := strings.Index(value, "#"); i != -1 {
+			// Trailing comment after an unquoted value
+			value = strings.TrimSpace(value[:i])
+		}
+		config.Defaults[key] = value
+	}
+	return config, scanner.Err()
+}
+
+// Sets every flag not given on the command line to its value from config, so flags override the config file
+func applyConfig(flags *flag.FlagSet, config Config) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for name, value := range config.Defaults {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q in config file", name)
+		}
+		if set[name] {
+			continue
+		}
+		err := flags.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s in config file: %v", value, name, err)
+		}
+	}
+	return nil
+}
+
+// Whether the flag called name has been set, on the command line or otherwise
+func flagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
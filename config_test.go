@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `# keybinding defaults
+direction = "West"
+wrap = horizontal # trailing comment
+gap = 8
+
+respect-struts = true
+`)
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	want := map[string]string{"direction": "West", "wrap": "horizontal", "gap": "8", "respect-struts": "true"}
+	for key, value := range want {
+		if config.Defaults[key] != value {
+			t.Errorf("config %s = %q, want %q", key, config.Defaults[key], value)
+		}
+	}
+	if len(config.Defaults) != len(want) {
+		t.Errorf("config = %v, want %v", config.Defaults, want)
+	}
+}
+
+func TestLoadConfigMissing(t *testing.T) {
+	config, err := loadConfig(filepath.Join(t.TempDir(), "missing.toml"))
+	if err != nil {
+		t.Errorf("loadConfig of a missing file error: %v", err)
+	}
+	if len(config.Defaults) != 0 {
+		t.Errorf("loadConfig of a missing file = %v, want no settings", config.Defaults)
+	}
+}
+
+func TestLoadConfigMalformed(t *testing.T) {
+	for _, contents := range []string{"direction West\n", "direction = \"West\n"} {
+		_, err := loadConfig(writeConfig(t, contents))
+		if err == nil {
+			t.Errorf("loadConfig(%q) accepted a malformed line", contents)
+		}
+	}
+}
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	flags := flag.NewFlagSet("go-to-monitor", flag.ContinueOnError)
+	direction := flags.String("direction", "East", "")
+	gap := flags.Int("gap", 0, "")
+	backend := flags.String("backend", "xinerama", "")
+	err := flags.Parse([]string{"-gap", "4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{Defaults: map[string]string{"direction": "West", "gap": "8"}}
+	err = applyConfig(flags, config)
+	if err != nil {
+		t.Fatalf("applyConfig error: %v", err)
+	}
+	// defaults < config file < flags
+	if *direction != "West" {
+		t.Errorf("direction = %q, want the config file's West", *direction)
+	}
+	if *gap != 4 {
+		t.Errorf("gap = %d, want the command line's 4", *gap)
+	}
+	if *backend != "xinerama" {
+		t.Errorf("backend = %q, want the default xinerama", *backend)
+	}
+}
+
+func TestApplyConfigErrors(t *testing.T) {
+	flags := flag.NewFlagSet("go-to-monitor", flag.ContinueOnError)
+	flags.Int("gap", 0, "")
+	for _, defaults := range []map[string]string{{"colour": "red"}, {"gap": "wide"}} {
+		err := applyConfig(flags, Config{Defaults: defaults})
+		if err == nil {
+			t.Errorf("applyConfig(%v) accepted an invalid setting", defaults)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+// Color and _NET_WM_WINDOW_OPACITY of the -confirm overlay, opacity is only honored with a compositor
+const (
+	overlayColor   = 0x3584e4
+	overlayOpacity = 0x60000000
+)
+
+// Rect the -confirm overlay covers on screen, inset from the edges so neighbouring monitors' overlays can't touch
+func overlayRect(screen xrect.Rect) xrect.Rect {
+	side := screen.Width()
+	if screen.Height() < side {
+		side = screen.Height()
+	}
+	return gotomonitor.ApplyGap(screen, side/20)
+}
+
+// Briefly covers screen with a translucent window, to show where a window is about to go
+func flashMonitor(X *xgbutil.XUtil, screen xrect.Rect, ms int) error {
+	overlay, err := xwindow.Generate(X)
+	if err != nil {
+		return err
+	}
+	geo := overlayRect(screen)
+	// Override redirect so the window manager neither decorates nor places it
+	overlay.Create(X.RootWin(), geo.X(), geo.Y(), geo.Width(), geo.Height(),
+		xproto.CwBackPixel|xproto.CwOverrideRedirect, overlayColor, 1)
+	defer overlay.Destroy()
+
+	err = xprop.ChangeProp32(X, overlay.Id, "_NET_WM_WINDOW_OPACITY", "CARDINAL", overlayOpacity)
+	if err != nil {
+		return err
+	}
+	overlay.Map()
+	X.Sync()
+
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	return nil
+}
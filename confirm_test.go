@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/xgbutil/xrect"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+func TestOverlayRect(t *testing.T) {
+	tests := []struct {
+		screen xrect.Rect
+		want   xrect.Rect
+	}{
+		{xrect.New(1920, 0, 1920, 1080), xrect.New(1974, 54, 1812, 972)},
+		// Portrait, inset by the same amount on every side
+		{xrect.New(0, 0, 1080, 1920), xrect.New(54, 54, 972, 1812)},
+		{xrect.New(0, 0, 10, 10), xrect.New(0, 0, 10, 10)},
+	}
+	for _, tt := range tests {
+		if got := overlayRect(tt.screen); !gotomonitor.SameRect(got, tt.want) {
+			t.Errorf("overlayRect(%v) = %v, want %v", tt.screen, got, tt.want)
+		}
+	}
+
+	// Overlays on monitors placed edge to edge don't touch
+	left := overlayRect(xrect.New(0, 0, 1920, 1080))
+	right := overlayRect(xrect.New(1920, 0, 1920, 1080))
+	if left.X()+left.Width() >= right.X() {
+		t.Errorf("overlays %v and %v touch", left, right)
+	}
+}
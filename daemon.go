@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// Socket the daemon listens on for commands
+func socketPath() (string, error) {
+	return runtimePath("go-to-monitor.sock")
+}
+
+// A command sent to the daemon, one per line:
+//
+//	move <direction> [wrap=<all|none|horizontal|vertical>]
+//	list
+//
+// The daemon answers with "ok", followed by any output, or "error: <message>" and closes the connection
+type command struct {
+	name string
+	opts options
+}
+
+// Parses a command line, options not given on the line are taken from defaults
+func parseCommand(line string, defaults options) (command, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return command{}, fmt.Errorf("empty command")
+	}
+
+	cmd := command{name: strings.ToLower(fields[0]), opts: defaults}
+	switch cmd.name {
+	case "list":
+		if len(fields) != 1 {
+			return command{}, fmt.Errorf("list takes no arguments")
+		}
+	case "move":
+		if len(fields) < 2 {
+			return command{}, fmt.Errorf("move needs a direction")
+		}
+		var err error
+		cmd.opts.dirs, err = parseDirSequence(fields[1])
+		if err != nil {
+			return command{}, err
+		}
+		for _, field := range fields[2:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return command{}, fmt.Errorf("expected key=value, got %q", field)
+			}
+			switch key {
+			case "wrap":
+				cmd.opts.wrap, err = parseWrap(value)
+				if err != nil {
+					return command{}, err
+				}
+			default:
+				return command{}, fmt.Errorf("unknown option %q", key)
+			}
+		}
+	default:
+		return command{}, fmt.Errorf("unknown command %q, expected move/list", fields[0])
+	}
+	return cmd, nil
+}
+
+// Long running process that keeps a connection to the X server open between moves
+type daemon struct {
+	X        *xgbutil.XUtil
+	backend  string
+	defaults options
+}
+
+// Runs a single command, returning the reply to send back
+func (d *daemon) handleCommand(line string) string {
+	return handleCommand(line, d.defaults, d.run)
+}
+
+// Parses line and hands the command to run, turning the result into a reply
+func handleCommand(line string, defaults options, run func(command) (string, error)) string {
+	cmd, err := parseCommand(line, defaults)
+	if err != nil {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+
+	output, err := run(cmd)
+	if err != nil {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+	return "ok\n" + output
+}
+
+func (d *daemon) run(cmd command) (string, error) {
+	// Monitors may have been plugged in since the last command
+	screens, err := heads(d.X, d.backend)
+	if err != nil {
+		return "", fmt.Errorf("error getting list of monitors: %v", err)
+	}
+
+	win, err := resolveTargetWindow(d.X, "")
+	if err != nil {
+		return "", err
+	}
+
+	switch cmd.name {
+	case "list":
+		current := -1
+		source_rect, err := selectSourceRect(win, cmd.opts.overlapBasis)
+		if err == nil {
+			current = resolveSourceIndex(source_rect, screens)
+		}
+		return formatScreens(screens, current), nil
+	case "move":
+		_, err := moveOne(d.X, cmd.opts, win, screens)
+		return "", err
+	}
+	return "", fmt.Errorf("unknown command %q", cmd.name)
+}
+
+// How long the daemon waits for a client to send its command
+var commandTimeout = 2 * time.Second
+
+// Accepts connections on listener until it is closed, one command per connection
+func (d *daemon) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		// Commands are handled one at a time, so X calls never overlap. A client that connects but never sends a
+		// command mustn't hold up everyone else
+		conn.SetReadDeadline(time.Now().Add(commandTimeout))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil && err != io.EOF {
+			log.Printf("Error reading command: %v", err)
+		} else {
+			debug.Printf("Received command %q", strings.TrimSpace(line))
+			io.WriteString(conn, d.handleCommand(line))
+		}
+		conn.Close()
+	}
+}
+
+// Sends a command to a running daemon and returns its reply
+func sendCommand(path string, line string) (string, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, line+"\n")
+	if err != nil {
+		return "", err
+	}
+	reply, err := io.ReadAll(conn)
+	return string(reply), err
+}
+
+// Calls cleanup once the process is interrupted or asked to terminate
+func setupSignalHandler(cleanup func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		debug.Printf("Received %v, shutting down", sig)
+		cleanup()
+	}()
+}
+
+// Stops listening on the socket at path and removes it, so the next daemon can listen there
+func closeSocket(listener net.Listener, path string) {
+	// Closing the listener removes the socket file too, remove it anyway in case that ever changes
+	listener.Close()
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: unable to remove socket: %v", err)
+	}
+}
+
+// Serves commands until interrupted, the caller closes X
+func runDaemon(X *xgbutil.XUtil, backend string, defaults options) error {
+	path, err := socketPath()
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	stopped := make(chan struct{})
+	setupSignalHandler(func() {
+		close(stopped)
+		closeSocket(listener, path)
+	})
+
+	d := &daemon{X: X, backend: backend, defaults: defaults}
+	err = d.serve(listener)
+	select {
+	case <-stopped:
+		return nil
+	default:
+		return err
+	}
+}
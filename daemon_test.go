@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+func TestParseCommand(t *testing.T) {
+	defaults := options{dirs: []gotomonitor.Ordinal{gotomonitor.East}, wrap: gotomonitor.WrapAll, gap: 8, overlapBasis: "client"}
+	tests := []struct {
+		line     string
+		wantName string
+		wantDirs []gotomonitor.Ordinal
+		wantWrap gotomonitor.Wrap
+		wantErr  bool
+	}{
+		{"list", "list", []gotomonitor.Ordinal{gotomonitor.East}, gotomonitor.WrapAll, false},
+		{"move West", "move", []gotomonitor.Ordinal{gotomonitor.West}, gotomonitor.WrapAll, false},
+		{"MOVE south,east wrap=none\n", "move", []gotomonitor.Ordinal{gotomonitor.South, gotomonitor.East}, gotomonitor.WrapNone, false},
+		{"move East wrap=true", "move", []gotomonitor.Ordinal{gotomonitor.East}, gotomonitor.WrapAll, false},
+		{"", "", nil, 0, true},
+		{"list monitors", "", nil, 0, true},
+		{"move", "", nil, 0, true},
+		{"move Eat", "", nil, 0, true},
+		{"move East wrap", "", nil, 0, true},
+		{"move East wrap=sideways", "", nil, 0, true},
+		{"move East gap=4", "", nil, 0, true},
+		{"resize East", "", nil, 0, true},
+	}
+	for _, tt := range tests {
+		cmd, err := parseCommand(tt.line, defaults)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCommand(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if cmd.name != tt.wantName || !reflect.DeepEqual(cmd.opts.dirs, tt.wantDirs) || cmd.opts.wrap != tt.wantWrap {
+			t.Errorf("parseCommand(%q) = %s %v wrap %v, want %s %v wrap %v",
+				tt.line, cmd.name, cmd.opts.dirs, cmd.opts.wrap, tt.wantName, tt.wantDirs, tt.wantWrap)
+		}
+		// Options the command doesn't mention keep their defaults
+		if cmd.opts.gap != defaults.gap {
+			t.Errorf("parseCommand(%q) gap = %d, want the default %d", tt.line, cmd.opts.gap, defaults.gap)
+		}
+		// list finds the current monitor the same way the command line does
+		if cmd.opts.overlapBasis != defaults.overlapBasis {
+			t.Errorf("parseCommand(%q) overlap basis = %q, want the default %q", tt.line, cmd.opts.overlapBasis, defaults.overlapBasis)
+		}
+	}
+}
+
+func TestHandleCommand(t *testing.T) {
+	var ran []command
+	run := func(cmd command) (string, error) {
+		ran = append(ran, cmd)
+		switch cmd.name {
+		case "list":
+			return "0  0,0 1920x1080 *current\n", nil
+		default:
+			return "", errors.New("window went away")
+		}
+	}
+
+	tests := []struct {
+		line string
+		want string
+		runs bool
+	}{
+		{"list\n", "ok\n0  0,0 1920x1080 *current\n", true},
+		{"move East\n", "error: window went away\n", true},
+		{"dance\n", "error: unknown command \"dance\", expected move/list\n", false},
+	}
+	for _, tt := range tests {
+		ran = nil
+		got := handleCommand(tt.line, options{}, run)
+		if got != tt.want {
+			t.Errorf("handleCommand(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+		if (len(ran) == 1) != tt.runs {
+			t.Errorf("handleCommand(%q) ran %d commands, want the command run %v", tt.line, len(ran), tt.runs)
+		}
+	}
+}
+
+func TestSignalHandlerRemovesSocket(t *testing.T) {
+	// Unix socket paths are short, so not under t.TempDir
+	dir, err := os.MkdirTemp("", "go-to-monitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "daemon.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listening on %s: %v", path, err)
+	}
+
+	done := make(chan struct{})
+	setupSignalHandler(func() {
+		closeSocket(listener, path)
+		close(done)
+	})
+	err = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	if err != nil {
+		t.Fatalf("sending SIGTERM: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cleanup didn't run after SIGTERM")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket %s still exists after cleanup: %v", path, err)
+	}
+	// The next daemon can listen there again
+	listener, err = net.Listen("unix", path)
+	if err != nil {
+		t.Errorf("listening again on %s: %v", path, err)
+	} else {
+		listener.Close()
+	}
+}
+
+func TestServeIdleClient(t *testing.T) {
+	defer func(timeout time.Duration) { commandTimeout = timeout }(commandTimeout)
+	commandTimeout = 50 * time.Millisecond
+
+	dir, err := os.MkdirTemp("", "go-to-monitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "daemon.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listening on %s: %v", path, err)
+	}
+	defer listener.Close()
+	go (&daemon{}).serve(listener)
+
+	// Connects and never sends a command
+	idle, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer idle.Close()
+
+	replies := make(chan string, 1)
+	go func() {
+		// Rejected before it needs an X connection
+		reply, _ := sendCommand(path, "dance")
+		replies <- reply
+	}()
+	select {
+	case reply := <-replies:
+		if !strings.HasPrefix(reply, "error: unknown command") {
+			t.Errorf("reply = %q, want an unknown command error", reply)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("an idle client blocked the daemon")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xwindow"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+// _NET_WM_DESKTOP of windows shown on every desktop
+const allDesktops = 0xFFFFFFFF
+
+// Whether -follow-desktop should switch desktops, and to which one, after a window has moved
+func desktopDecision(windowDesktop, currentDesktop uint) (desktop uint, switchDesktop bool) {
+	if windowDesktop == allDesktops || windowDesktop == currentDesktop {
+		// Window is already visible
+		return currentDesktop, false
+	}
+	return windowDesktop, true
+}
+
+// Switches to the desktop win lives on if it isn't the current one, so a window the window manager
+// put on another monitor's desktop doesn't disappear from view
+func followDesktop(X *xgbutil.XUtil, win *xwindow.Window) error {
+	window_desktop, err := ewmh.WmDesktopGet(X, win.Id)
+	if err != nil {
+		return err
+	}
+	current_desktop, err := ewmh.CurrentDesktopGet(X)
+	if err != nil {
+		return err
+	}
+
+	desktop, switch_desktop := desktopDecision(window_desktop, current_desktop)
+	if !switch_desktop {
+		return nil
+	}
+	debug.Printf("Switching from desktop %d to desktop %d", current_desktop, desktop)
+	return ewmh.CurrentDesktopReq(X, int(desktop))
+}
+
+// Desktop offset desktops on from current out of count, wrapping around or stopping at the first and last desktops
+func nextDesktop(current, offset, count int, wrap bool) int {
+	if count <= 0 {
+		return current
+	}
+	next := current + offset
+	if wrap {
+		return ((next % count) + count) % count
+	}
+	if next < 0 {
+		return 0
+	}
+	if next >= count {
+		return count - 1
+	}
+	return next
+}
+
+// Sends win offset desktops on from the one it is on, sticky windows are already on every desktop
+func shiftDesktop(X *xgbutil.XUtil, win *xwindow.Window, offset int, wrap bool) error {
+	current, err := ewmh.WmDesktopGet(X, win.Id)
+	if err != nil {
+		return err
+	}
+	if current == allDesktops {
+		debug.Printf("Window %d is on every desktop, not changing its desktop", win.Id)
+		return nil
+	}
+	count, err := ewmh.NumberOfDesktopsGet(X)
+	if err != nil {
+		return err
+	}
+
+	desktop := nextDesktop(int(current), offset, int(count), wrap)
+	debug.Printf("Moving window %d from desktop %d to desktop %d", win.Id, current, desktop)
+	return ewmh.WmDesktopReqExtra(X, win.Id, uint(desktop), int(gotomonitor.Pager))
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestDesktopDecision(t *testing.T) {
+	tests := []struct {
+		name                          string
+		windowDesktop, currentDesktop uint
+		want                          uint
+		wantSwitch                    bool
+	}{
+		{"window on the current desktop", 1, 1, 1, false},
+		{"window on every desktop", allDesktops, 2, 2, false},
+		// e.g. a window manager with a desktop per monitor
+		{"window on the target monitor's desktop", 3, 0, 3, true},
+		{"window on the first desktop", 0, 2, 0, true},
+	}
+	for _, tt := range tests {
+		desktop, switch_desktop := desktopDecision(tt.windowDesktop, tt.currentDesktop)
+		if desktop != tt.want || switch_desktop != tt.wantSwitch {
+			t.Errorf("%s: desktopDecision(%d, %d) = %d %v, want %d %v",
+				tt.name, tt.windowDesktop, tt.currentDesktop, desktop, switch_desktop, tt.want, tt.wantSwitch)
+		}
+	}
+}
+
+func TestNextDesktop(t *testing.T) {
+	tests := []struct {
+		current, offset int
+		wrap            bool
+		want            int
+	}{
+		{0, 1, false, 1},
+		{3, 1, false, 3},
+		{0, -1, false, 0},
+		{1, 5, false, 3},
+		{3, 1, true, 0},
+		{0, -1, true, 3},
+		{2, 6, true, 0},
+	}
+	for _, tt := range tests {
+		if got := nextDesktop(tt.current, tt.offset, 4, tt.wrap); got != tt.want {
+			t.Errorf("nextDesktop(%d, %+d, 4, %v) = %d, want %d", tt.current, tt.offset, tt.wrap, got, tt.want)
+		}
+	}
+	if got := nextDesktop(2, 1, 0, true); got != 2 {
+		t.Errorf("nextDesktop without desktops = %d, want the current desktop", got)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Everything that decides whether and how a window is moved, printed by -dump-ewmh
+type windowInfo struct {
+	ID      xproto.Window
+	Name    string
+	State   []string
+	Types   []string
+	Desktop string
+	Frame   xrect.Rect
+	Monitor int
+}
+
+func formatWindowInfo(info windowInfo) string {
+	var b strings.Builder
+	line := func(name, value string) {
+		fmt.Fprintf(&b, "%-20s %s\n", name, value)
+	}
+	line("window", fmt.Sprintf("0x%x", uint32(info.ID)))
+	line("_NET_WM_NAME", fmt.Sprintf("%q", info.Name))
+	line("_NET_WM_STATE", strings.Join(info.State, " "))
+	line("_NET_WM_WINDOW_TYPE", strings.Join(info.Types, " "))
+	line("_NET_WM_DESKTOP", info.Desktop)
+	line("frame", fmt.Sprintf("%d,%d %dx%d", info.Frame.X(), info.Frame.Y(), info.Frame.Width(), info.Frame.Height()))
+	line("monitor", fmt.Sprint(info.Monitor))
+	return b.String()
+}
+
+// Gathers and formats the EWMH state of win for troubleshooting, missing properties are shown empty
+func dumpWindowInfo(X *xgbutil.XUtil, win *xwindow.Window, screens []xrect.Rect) (string, error) {
+	frame, err := win.DecorGeometry()
+	if err != nil {
+		return "", fmt.Errorf("error getting window geometry: %v", err)
+	}
+
+	props := windowProperties{
+		name:    func() (string, error) { return ewmh.WmNameGet(X, win.Id) },
+		state:   func() ([]string, error) { return ewmh.WmStateGet(X, win.Id) },
+		types:   func() ([]string, error) { return ewmh.WmWindowTypeGet(X, win.Id) },
+		desktop: func() (uint, error) { return ewmh.WmDesktopGet(X, win.Id) },
+	}
+	return formatWindowInfo(gatherWindowInfo(win.Id, frame, screens, props)), nil
+}
+
+// Getters for the EWMH properties of a window shown by -dump-ewmh
+type windowProperties struct {
+	name    func() (string, error)
+	state   func() ([]string, error)
+	types   func() ([]string, error)
+	desktop func() (uint, error)
+}
+
+func gatherWindowInfo(id xproto.Window, frame xrect.Rect, screens []xrect.Rect, props windowProperties) windowInfo {
+	info := windowInfo{
+		ID:      id,
+		Desktop: "none",
+		Frame:   frame,
+		Monitor: xrect.LargestOverlap(frame, screens),
+	}
+	info.Name, _ = props.name()
+	info.State, _ = props.state()
+	info.Types, _ = props.types()
+	if desktop, err := props.desktop(); err == nil {
+		if desktop == allDesktops {
+			info.Desktop = "all"
+		} else {
+			info.Desktop = fmt.Sprint(desktop)
+		}
+	}
+	return info
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+func TestDumpWindowInfo(t *testing.T) {
+	props := windowProperties{
+		name:    func() (string, error) { return "Terminal", nil },
+		state:   func() ([]string, error) { return []string{"_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_ABOVE"}, nil },
+		types:   func() ([]string, error) { return []string{"_NET_WM_WINDOW_TYPE_NORMAL"}, nil },
+		desktop: func() (uint, error) { return 2, nil },
+	}
+	got := formatWindowInfo(gatherWindowInfo(0x1e00004, xrect.New(2000, 100, 800, 600), sideBySide(), props))
+	want := "window               0x1e00004\n" +
+		"_NET_WM_NAME         \"Terminal\"\n" +
+		"_NET_WM_STATE        _NET_WM_STATE_MAXIMIZED_HORZ _NET_WM_STATE_ABOVE\n" +
+		"_NET_WM_WINDOW_TYPE  _NET_WM_WINDOW_TYPE_NORMAL\n" +
+		"_NET_WM_DESKTOP      2\n" +
+		"frame                2000,100 800x600\n" +
+		"monitor              1\n"
+	if got != want {
+		t.Errorf("window info =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDumpWindowInfoMissingProperties(t *testing.T) {
+	unset := errors.New("property not set")
+	props := windowProperties{
+		name:    func() (string, error) { return "", unset },
+		state:   func() ([]string, error) { return nil, unset },
+		types:   func() ([]string, error) { return nil, unset },
+		desktop: func() (uint, error) { return 0, unset },
+	}
+	info := gatherWindowInfo(1, xrect.New(100, 100, 800, 600), sideBySide(), props)
+	if info.Desktop != "none" || info.Name != "" || info.State != nil || info.Types != nil || info.Monitor != 0 {
+		t.Errorf("window info without properties = %+v", info)
+	}
+
+	props.desktop = func() (uint, error) { return allDesktops, nil }
+	if info := gatherWindowInfo(1, xrect.New(100, 100, 800, 600), sideBySide(), props); info.Desktop != "all" {
+		t.Errorf("desktop of a sticky window = %q, want all", info.Desktop)
+	}
+}
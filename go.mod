@@ -3,8 +3,8 @@ module danielcranford/go-to-monitor
 go 1.18
 
 require (
+	github.com/BurntSushi/xgb v0.0.0-20210121224620-deaf085860bc
 	github.com/BurntSushi/xgbutil v0.0.0-20190907113008-ad855c713046
+	github.com/godbus/dbus/v5 v5.1.0
 	golang.org/x/exp v0.0.0-20231006140011-7918f672742d
 )
-
-require github.com/BurntSushi/xgb v0.0.0-20210121224620-deaf085860bc // indirect
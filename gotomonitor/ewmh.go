@@ -0,0 +1,408 @@
+package gotomonitor
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// This should be in xbgutil
+type EwmhClientSource int
+
+const (
+	Unknown EwmhClientSource = iota
+	Application
+	Pager
+)
+
+func WmStateReqExtra2(win xwindow.Window, action int, source EwmhClientSource,
+	atoms ...string) error {
+
+	return sendStatePairs(atoms, func(first, second string) error {
+		return ewmh.WmStateReqExtra(win.X, win.Id, action, first, second, int(source))
+	})
+}
+
+// Calls send with atoms two at a time, then with the last atom and "" if there is an odd number of them
+func sendStatePairs(atoms []string, send func(first, second string) error) error {
+	var i int
+	for i = 0; i < len(atoms)/2; i++ {
+		// ewmh _NET_WM_STATE client message accepts 2 atoms at a time
+		// unknown if a simple property update to the _NET_WM_STATE property is supported since ewmh specifies the _NET_WM_STATE value must be updated via the client message
+		first := atoms[i*2]
+		second := atoms[i*2+1]
+		err := send(first, second)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Finish the tail
+	if i*2 < len(atoms) {
+		err := send(atoms[i*2], "")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// xwindow.adjustSize has a bug where parent window is not retrieved
+// AdjustSize takes a client and dimensions, and adjust them so that they'll
+// account for window decorations. For example, if you want a window to be
+// 200 pixels wide, a window manager will typically determine that as
+// you wanting the *client* to be 200 pixels wide. The end result is that
+// the client plus decorations ends up being
+// (200 + left decor width + right decor width) pixels wide. Which is probably
+// not what you want. Therefore, transform 200 into
+// 200 - decoration window width - client window width.
+// Similarly for height.
+func AdjustSize(win xwindow.Window,
+	w, h int) (int, int, error) {
+
+	// raw client geometry
+	cGeom, err := xwindow.RawGeometry(win.X, xproto.Drawable(win.Id))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// geometry with decorations
+	decorations, err := DecorWindow(&win)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pGeom, err := xwindow.RawGeometry(win.X, xproto.Drawable(decorations.Id))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	neww, newh := decorAdjustedSize(w, h, cGeom, pGeom)
+	return neww, newh, nil
+}
+
+// Subtracts the size of the decorations (the difference between frame and client) from w and h.
+// Non-reparented windows are their own frame and need no adjustment, and a frame that is somehow
+// smaller than its client is treated as having no decorations rather than growing the window
+func decorAdjustedSize(w, h int, client, frame xrect.Rect) (int, int) {
+	decorw := frame.Width() - client.Width()
+	decorh := frame.Height() - client.Height()
+	if decorw < 0 {
+		decorw = 0
+	}
+	if decorh < 0 {
+		decorh = 0
+	}
+
+	neww := w - decorw
+	newh := h - decorh
+	if neww < 1 {
+		neww = 1
+	}
+	if newh < 1 {
+		newh = 1
+	}
+	return neww, newh
+}
+
+// Gravity sent with move requests, one of the xproto.Gravity* constants. With NorthWest the position is that of
+// the top left corner of the window's frame
+var Gravity = xproto.GravityNorthWest
+
+// Whether WMMoveResize subtracts the size of a window's decorations, not needed with undecorated (e.g. tiling) window
+// managers, where skipping it saves looking up the window's frame
+var AdjustDecorations = true
+
+// xwindow.WMMoveResize has a bug where decorations are not accounted for
+//
+// WMMoveResize is an accurate means of resizing a window, accounting for
+// decorations. Usually, the x,y coordinates are fine---we just need to
+// adjust the width and height.
+// This should be used when moving/resizing top-level client windows with
+// reparenting window managers that support EWMH.
+func WMMoveResize(w xwindow.Window, x, y, width, height int) error {
+	neww, newh, err := requestedSize(width, height, AdjustDecorations, func(width, height int) (int, int, error) {
+		return AdjustSize(w, width, height)
+	})
+	if err != nil {
+		return err
+	}
+	// Most windows don't set WM_NORMAL_HINTS
+	hints, err := icccm.WmNormalHintsGet(w.X, w.Id)
+	if err == nil {
+		neww, newh = ApplySizeHints(neww, newh, hints)
+	}
+	return ewmh.MoveresizeWindowExtra(w.X, w.Id, x, y, neww, newh,
+		Gravity, 2, true, true)
+}
+
+// Size to request for a window width by height including decorations, adjust is only called to subtract them when
+// adjustDecorations is set
+func requestedSize(width, height int, adjustDecorations bool, adjust func(width, height int) (int, int, error)) (int, int, error) {
+	if !adjustDecorations {
+		return width, height, nil
+	}
+	return adjust(width, height)
+}
+
+// Logic lifted from xwindow.DecorGeometry
+// The window manager may reparent the window while the tree is being walked (e.g. when it was just restacked), so
+// the frame found is checked to still contain the window rather than risk returning some other client's frame
+func DecorWindow(w *xwindow.Window) (*xwindow.Window, error) {
+	parent := w
+	chain := []xproto.Window{w.Id}
+	for {
+		tempParent, err := parent.Parent()
+		if err != nil {
+			return parent, err
+		}
+		if tempParent.Id == w.X.RootWin() {
+			break
+		}
+		parent = tempParent
+		chain = append(chain, parent.Id)
+	}
+
+	err := checkAncestry(chain, func(win xproto.Window) ([]xproto.Window, error) {
+		tree, err := xproto.QueryTree(w.X.Conn(), win).Reply()
+		if err != nil {
+			return nil, err
+		}
+		return tree.Children, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// Checks that each window in chain is a child of the window after it, so chain runs from a window up to its frame
+func checkAncestry(chain []xproto.Window, children func(xproto.Window) ([]xproto.Window, error)) error {
+	for i := 0; i+1 < len(chain); i++ {
+		kids, err := children(chain[i+1])
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, kid := range kids {
+			if kid == chain[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("window %d is no longer a child of %d, window was reparented while finding its frame", chain[i], chain[i+1])
+		}
+	}
+	return nil
+}
+
+// States that prevent a window from being moved across monitors, removed before moving and restored afterwards
+var BlockingStates = []string{
+	"_NET_WM_STATE_MAXIMIZED_HORZ",
+	"_NET_WM_STATE_MAXIMIZED_VERT",
+	"_NET_WM_STATE_FULLSCREEN",
+}
+
+// States that leave a window in a half-state if it is moved with them set, always removed before moving and restored
+// afterwards, even when blocking states are not restored
+var SuspendedStates = []string{
+	"_NET_WM_STATE_SHADED",
+}
+
+// Filters a window's _NET_WM_STATE down to the states that prevent it from being moved across monitors
+func StatesBlockingMove(state []string) []string {
+	return intersectStates(state, BlockingStates)
+}
+
+// States removed from a window before it is moved and added back afterwards
+type StateChange struct {
+	Removed  []string
+	Restored []string
+}
+
+// Only the blocking states the window actually has are removed, and exactly those are restored (plus any extra states),
+// so a fullscreen window comes back fullscreen rather than maximized
+func PlanStateChange(state []string, extraStates []string) StateChange {
+	removed := StatesBlockingMove(state)
+	return StateChange{
+		Removed:  removed,
+		Restored: mergeStates(removed, extraStates),
+	}
+}
+
+// Moves a window to geo, temporarily removing any states that would prevent the window manager from moving it.
+// Any extra states are added along with the restored states once the window has moved
+func MoveWindow(win *xwindow.Window, geo xrect.Rect, extraStates ...string) error {
+	return moveWindow(win, geo, func(state []string) StateChange {
+		return PlanStateChange(state, extraStates)
+	})
+}
+
+// Moves a window to geo, leaving it without the states that had to be removed to move it (e.g. unmaximized).
+// Any extra states are still added once the window has moved
+func MoveWindowUnrestored(win *xwindow.Window, geo xrect.Rect, extraStates ...string) error {
+	return moveWindow(win, geo, func(state []string) StateChange {
+		return planUnrestored(state, extraStates)
+	})
+}
+
+// Removes the blocking states without putting them back, only extraStates are added
+func planUnrestored(state []string, extraStates []string) StateChange {
+	return StateChange{
+		Removed:  StatesBlockingMove(state),
+		Restored: extraStates,
+	}
+}
+
+// Moves a window to geo, replacing any states that would prevent the window manager from moving it with the
+// blocking states in restore, e.g. to put a window back exactly how it was before an earlier move
+func MoveWindowWithStates(win *xwindow.Window, geo xrect.Rect, restore []string) error {
+	return moveWindow(win, geo, func(state []string) StateChange {
+		return StateChange{
+			Removed:  StatesBlockingMove(state),
+			Restored: StatesBlockingMove(restore),
+		}
+	})
+}
+
+// Whether a window with this _NET_WM_STATE fills its monitor, being maximized both ways or fullscreen.
+// Only the BlockingStates count, a state left out of -strip-states isn't removed and restored around the move, so the
+// window manager won't size the window to the new monitor from it
+func FillsMonitor(state []string) bool {
+	horz, vert := false, false
+	for _, x := range StatesBlockingMove(state) {
+		switch x {
+		case "_NET_WM_STATE_FULLSCREEN":
+			return true
+		case "_NET_WM_STATE_MAXIMIZED_HORZ":
+			horz = true
+		case "_NET_WM_STATE_MAXIMIZED_VERT":
+			vert = true
+		}
+	}
+	return horz && vert
+}
+
+// Moves a window that fills its monitor onto screen without setting its size, the window manager sizes it to the
+// new monitor once its states are restored. Resizing it as well races with the window manager and can leave the
+// window maximized at the size of the old monitor
+func MoveMaximizedWindow(win *xwindow.Window, screen xrect.Rect, extraStates ...string) error {
+	plan := func(state []string) StateChange {
+		return PlanStateChange(state, extraStates)
+	}
+	return changeStatesAround(win, plan, func() error {
+		// Width and height of 0 aren't sent, only the position
+		return ewmh.MoveresizeWindowExtra(win.X, win.Id, screen.X(), screen.Y(), 0, 0, Gravity, 2, true, true)
+	})
+}
+
+func moveWindow(win *xwindow.Window, geo xrect.Rect, plan func(state []string) StateChange) error {
+	return changeStatesAround(win, plan, func() error {
+		// TODO: xwindow.WMMoveResize has a bug in current version of xbgutil
+		return WMMoveResize(*win, geo.X(), geo.Y(), geo.Width(), geo.Height())
+	})
+}
+
+// Removes the states plan says to, calls move, then adds the states plan says to restore
+func changeStatesAround(win *xwindow.Window, plan func(state []string) StateChange, move func() error) error {
+	getState := func() ([]string, error) {
+		return ewmh.WmStateGet(win.X, win.Id)
+	}
+	request := func(action int, atoms []string) error {
+		return WmStateReqExtra2(*win, action, Pager, atoms...)
+	}
+	wait := func(atoms []string) error {
+		if StateTimeout <= 0 {
+			return nil
+		}
+		return waitForStateCleared(win.X, win, atoms, StateTimeout)
+	}
+	return applyStateChange(getState, request, wait, plan, move)
+}
+
+// The steps of changeStatesAround, with the window's state read by getState, state change requests sent by request
+// and wait returning once the window manager has removed atoms
+func applyStateChange(getState func() ([]string, error), request func(action int, atoms []string) error,
+	wait func(atoms []string) error, plan func(state []string) StateChange, move func() error) error {
+
+	// Retrieve properties that must be removed prior to moving, see BlockingStates
+	state, err := getState()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve window's state: %w", err)
+	}
+	change := withSuspended(plan(state), state)
+
+	err = request(ewmh.StateRemove, change.Removed)
+	if err != nil {
+		return fmt.Errorf("unable to update _NET_WM_STATE to make window moveable: %w", err)
+	}
+	err = wait(change.Removed)
+	if err != nil {
+		// Try the move anyway, most of the time it works
+		Debug.Printf("Moving before window manager removed state: %v", err)
+	}
+
+	// Move window
+	err = move()
+	if err != nil {
+		return fmt.Errorf("unable to move window: %w", err)
+	}
+
+	// Restore maximized/fullscreen/shaded state
+	err = request(ewmh.StateAdd, change.Restored)
+	if err != nil {
+		return fmt.Errorf("unable to restore _NET_WM_STATE after moving window: %w", err)
+	}
+
+	return nil
+}
+
+// Adds the SuspendedStates a window with this state has to both sides of change
+func withSuspended(change StateChange, state []string) StateChange {
+	suspended := intersectStates(state, SuspendedStates)
+	return StateChange{
+		Removed:  mergeStates(change.Removed, suspended),
+		Restored: mergeStates(change.Restored, suspended),
+	}
+}
+
+// Union of two lists of state atoms, without duplicates
+func mergeStates(states []string, extra []string) []string {
+	merged := make([]string, 0, len(states)+len(extra))
+	seen := make(map[string]bool, len(states)+len(extra))
+	for _, list := range [][]string{states, extra} {
+		for _, x := range list {
+			if !seen[x] {
+				seen[x] = true
+				merged = append(merged, x)
+			}
+		}
+	}
+	return merged
+}
+
+// Asks the window manager to raise a window to the top of the stack and give it focus
+func RaiseAndFocus(X *xgbutil.XUtil, win *xwindow.Window) error {
+	err := Raise(X, win)
+	if err != nil {
+		return err
+	}
+	return Focus(X, win)
+}
+
+// Asks the window manager to raise a window to the top of the stack
+func Raise(X *xgbutil.XUtil, win *xwindow.Window) error {
+	return ewmh.RestackWindowExtra(X, win.Id, xproto.StackModeAbove, 0, int(Pager))
+}
+
+// Asks the window manager to make a window the active window
+func Focus(X *xgbutil.XUtil, win *xwindow.Window) error {
+	return ewmh.ActiveWindowReqExtra(X, win.Id, int(Pager), 0, 0)
+}
@@ -0,0 +1,346 @@
+package gotomonitor
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// Records the atom pairs that would have been sent to the window manager
+type fakeStateSink struct {
+	pairs [][2]string
+}
+
+func (f *fakeStateSink) send(first, second string) error {
+	f.pairs = append(f.pairs, [2]string{first, second})
+	return nil
+}
+
+func TestSendStatePairs(t *testing.T) {
+	tests := []struct {
+		name  string
+		atoms []string
+		want  [][2]string
+	}{
+		{"none", nil, nil},
+		{"one", []string{"A"}, [][2]string{{"A", ""}}},
+		{"two", []string{"A", "B"}, [][2]string{{"A", "B"}}},
+		{"three", []string{"A", "B", "C"}, [][2]string{{"A", "B"}, {"C", ""}}},
+		{"four", []string{"A", "B", "C", "D"}, [][2]string{{"A", "B"}, {"C", "D"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sink fakeStateSink
+			err := sendStatePairs(tt.atoms, sink.send)
+			if err != nil {
+				t.Fatalf("sendStatePairs(%v) error: %v", tt.atoms, err)
+			}
+			if !reflect.DeepEqual(sink.pairs, tt.want) {
+				t.Errorf("sendStatePairs(%v) sent %v, want %v", tt.atoms, sink.pairs, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendStatePairsStopsOnError(t *testing.T) {
+	calls := 0
+	failure := errors.New("bad atom")
+	err := sendStatePairs([]string{"A", "B", "C"}, func(first, second string) error {
+		calls++
+		return failure
+	})
+	if err != failure {
+		t.Errorf("sendStatePairs error = %v, want %v", err, failure)
+	}
+	if calls != 1 {
+		t.Errorf("sendStatePairs made %d requests after an error, want 1", calls)
+	}
+}
+
+func TestStatesBlockingMove(t *testing.T) {
+	tests := []struct {
+		state []string
+		want  []string
+	}{
+		{nil, nil},
+		{[]string{"_NET_WM_STATE_ABOVE", "_NET_WM_STATE_STICKY"}, nil},
+		{
+			[]string{"_NET_WM_STATE_ABOVE", "_NET_WM_STATE_MAXIMIZED_VERT", "_NET_WM_STATE_FOCUSED", "_NET_WM_STATE_MAXIMIZED_HORZ"},
+			[]string{"_NET_WM_STATE_MAXIMIZED_VERT", "_NET_WM_STATE_MAXIMIZED_HORZ"},
+		},
+		{
+			[]string{"_NET_WM_STATE_FULLSCREEN", "_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_MAXIMIZED_VERT", "_NET_WM_STATE_SKIP_TASKBAR"},
+			[]string{"_NET_WM_STATE_FULLSCREEN", "_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_MAXIMIZED_VERT"},
+		},
+	}
+	for _, tt := range tests {
+		got := StatesBlockingMove(tt.state)
+		if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("StatesBlockingMove(%v) = %q, want %q", tt.state, got, tt.want)
+		}
+		for _, atom := range got {
+			if atom == "" {
+				t.Errorf("StatesBlockingMove(%v) = %q, has an empty atom", tt.state, got)
+			}
+		}
+	}
+}
+
+func TestPlanStateChangeExtraStates(t *testing.T) {
+	horz, vert := "_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_MAXIMIZED_VERT"
+	// A window maximized horizontally and moved with -maximize=both gets each state back once
+	got := PlanStateChange([]string{horz, "_NET_WM_STATE_ABOVE"}, []string{horz, vert})
+	want := StateChange{Removed: []string{horz}, Restored: []string{horz, vert}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PlanStateChange = %+v, want %+v", got, want)
+	}
+}
+
+// A window whose _NET_WM_STATE the window manager updates as soon as it is asked to, recording each step
+type fakeStateWindow struct {
+	state []string
+	steps []string
+}
+
+func (f *fakeStateWindow) getState() ([]string, error) {
+	return f.state, nil
+}
+
+func (f *fakeStateWindow) request(action int, atoms []string) error {
+	if len(atoms) == 0 {
+		return nil
+	}
+	switch action {
+	case ewmh.StateRemove:
+		f.steps = append(f.steps, "remove "+strings.Join(atoms, " "))
+		var kept []string
+		for _, x := range f.state {
+			if len(intersectStates([]string{x}, atoms)) == 0 {
+				kept = append(kept, x)
+			}
+		}
+		f.state = kept
+	case ewmh.StateAdd:
+		f.steps = append(f.steps, "add "+strings.Join(atoms, " "))
+		f.state = mergeStates(f.state, atoms)
+	}
+	return nil
+}
+
+func (f *fakeStateWindow) wait(atoms []string) error {
+	return nil
+}
+
+func (f *fakeStateWindow) move() error {
+	f.steps = append(f.steps, "move")
+	return nil
+}
+
+func TestApplyStateChangeFullscreen(t *testing.T) {
+	win := &fakeStateWindow{state: []string{"_NET_WM_STATE_FULLSCREEN", "_NET_WM_STATE_ABOVE"}}
+	plan := func(state []string) StateChange { return PlanStateChange(state, nil) }
+	err := applyStateChange(win.getState, win.request, win.wait, plan, win.move)
+	if err != nil {
+		t.Fatalf("applyStateChange: %v", err)
+	}
+
+	want_steps := []string{"remove _NET_WM_STATE_FULLSCREEN", "move", "add _NET_WM_STATE_FULLSCREEN"}
+	if !reflect.DeepEqual(win.steps, want_steps) {
+		t.Errorf("steps = %q, want %q", win.steps, want_steps)
+	}
+	// Fullscreen again, not maximized
+	want_state := []string{"_NET_WM_STATE_ABOVE", "_NET_WM_STATE_FULLSCREEN"}
+	if !reflect.DeepEqual(win.state, want_state) {
+		t.Errorf("state after moving = %q, want %q", win.state, want_state)
+	}
+}
+
+func TestApplyStateChangeNoRestore(t *testing.T) {
+	horz, vert := "_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_MAXIMIZED_VERT"
+	tests := []struct {
+		name       string
+		plan       func(state []string) StateChange
+		want_steps []string
+	}{
+		{"restoring", func(state []string) StateChange { return PlanStateChange(state, nil) },
+			[]string{"remove " + horz + " " + vert, "move", "add " + horz + " " + vert}},
+		{"-no-restore-state", func(state []string) StateChange { return planUnrestored(state, nil) },
+			[]string{"remove " + horz + " " + vert, "move"}},
+	}
+	for _, tt := range tests {
+		win := &fakeStateWindow{state: []string{horz, vert}}
+		if err := applyStateChange(win.getState, win.request, win.wait, tt.plan, win.move); err != nil {
+			t.Fatalf("%s: applyStateChange: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(win.steps, tt.want_steps) {
+			t.Errorf("%s: steps = %q, want %q", tt.name, win.steps, tt.want_steps)
+		}
+	}
+}
+
+func TestApplyStateChangeKeepAbove(t *testing.T) {
+	tests := []struct {
+		name       string
+		extra      []string
+		want_steps []string
+	}{
+		{"without -keep-above", nil, []string{"move"}},
+		// Re-asserted even though the window already has it, the window manager may drop it while moving
+		{"with -keep-above", []string{"_NET_WM_STATE_ABOVE"}, []string{"move", "add _NET_WM_STATE_ABOVE"}},
+	}
+	for _, tt := range tests {
+		win := &fakeStateWindow{state: []string{"_NET_WM_STATE_ABOVE"}}
+		plan := func(state []string) StateChange { return PlanStateChange(state, tt.extra) }
+		if err := applyStateChange(win.getState, win.request, win.wait, plan, win.move); err != nil {
+			t.Fatalf("%s: applyStateChange: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(win.steps, tt.want_steps) {
+			t.Errorf("%s: steps = %q, want %q", tt.name, win.steps, tt.want_steps)
+		}
+	}
+}
+
+func TestApplyStateChangeShaded(t *testing.T) {
+	shaded, horz := "_NET_WM_STATE_SHADED", "_NET_WM_STATE_MAXIMIZED_HORZ"
+	tests := []struct {
+		name       string
+		state      []string
+		plan       func(state []string) StateChange
+		want_steps []string
+	}{
+		{"shaded", []string{shaded}, func(state []string) StateChange { return PlanStateChange(state, nil) },
+			[]string{"remove " + shaded, "move", "add " + shaded}},
+		{"shaded and maximized", []string{horz, shaded}, func(state []string) StateChange { return PlanStateChange(state, nil) },
+			[]string{"remove " + horz + " " + shaded, "move", "add " + horz + " " + shaded}},
+		// Shading comes back even when maximizing doesn't
+		{"-no-restore-state", []string{horz, shaded}, func(state []string) StateChange { return planUnrestored(state, nil) },
+			[]string{"remove " + horz + " " + shaded, "move", "add " + shaded}},
+	}
+	for _, tt := range tests {
+		win := &fakeStateWindow{state: tt.state}
+		if err := applyStateChange(win.getState, win.request, win.wait, tt.plan, win.move); err != nil {
+			t.Fatalf("%s: applyStateChange: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(win.steps, tt.want_steps) {
+			t.Errorf("%s: steps = %q, want %q", tt.name, win.steps, tt.want_steps)
+		}
+	}
+}
+
+func TestFillsMonitor(t *testing.T) {
+	horz, vert := "_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_MAXIMIZED_VERT"
+	tests := []struct {
+		state []string
+		want  bool
+	}{
+		{nil, false},
+		{[]string{"_NET_WM_STATE_ABOVE"}, false},
+		{[]string{horz}, false},
+		{[]string{vert, "_NET_WM_STATE_STICKY"}, false},
+		{[]string{horz, vert}, true},
+		{[]string{vert, "_NET_WM_STATE_ABOVE", horz}, true},
+		{[]string{"_NET_WM_STATE_FULLSCREEN"}, true},
+		{[]string{"_NET_WM_STATE_HIDDEN", horz, vert}, true},
+	}
+	for _, tt := range tests {
+		if got := FillsMonitor(tt.state); got != tt.want {
+			t.Errorf("FillsMonitor(%q) = %v, want %v", tt.state, got, tt.want)
+		}
+	}
+
+	// With -strip-states=fullscreen maximized windows keep their states while moving and are sized like any other
+	defer func(states []string) { BlockingStates = states }(BlockingStates)
+	BlockingStates = []string{"_NET_WM_STATE_FULLSCREEN"}
+	if FillsMonitor([]string{"_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_MAXIMIZED_VERT"}) {
+		t.Errorf("FillsMonitor(maximized) = true with maximized states left out of BlockingStates")
+	}
+	if !FillsMonitor([]string{"_NET_WM_STATE_FULLSCREEN"}) {
+		t.Errorf("FillsMonitor(fullscreen) = false with fullscreen in BlockingStates")
+	}
+}
+
+func TestCheckAncestry(t *testing.T) {
+	// Client 3 reparented into frame 2, which is a child of the root window 1. Frame 5 holds client 4
+	tree := map[xproto.Window][]xproto.Window{1: {2, 5}, 2: {3}, 5: {4}}
+	children := func(win xproto.Window) ([]xproto.Window, error) {
+		kids, ok := tree[win]
+		if !ok {
+			return nil, xproto.WindowError{NiceName: "Window", BadValue: uint32(win)}
+		}
+		return kids, nil
+	}
+
+	tests := []struct {
+		name    string
+		chain   []xproto.Window
+		wantErr bool
+	}{
+		{"not reparented", []xproto.Window{3}, false},
+		{"reparented", []xproto.Window{3, 2}, false},
+		// Walked up into another client's frame after a restack
+		{"sibling frame", []xproto.Window{3, 5}, true},
+		{"frame destroyed", []xproto.Window{3, 6}, true},
+	}
+	for _, tt := range tests {
+		err := checkAncestry(tt.chain, children)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkAncestry(%v) error = %v, want error %v", tt.name, tt.chain, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDecorAdjustedSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		client, frame xrect.Rect
+		wantW, wantH  int
+	}{
+		// 2px borders and a 24px title bar
+		{"reparented", xrect.New(2, 24, 796, 574), xrect.New(100, 100, 800, 600), 796, 574},
+		// The frame is the client itself
+		{"not reparented", xrect.New(100, 100, 800, 600), xrect.New(100, 100, 800, 600), 800, 600},
+		// A nested frame reporting less than the client, which would make the window grow
+		{"frame smaller than client", xrect.New(0, 0, 820, 640), xrect.New(100, 100, 800, 600), 800, 600},
+		{"decorations larger than the window", xrect.New(0, 0, 10, 10), xrect.New(0, 0, 2000, 1500), 1, 1},
+	}
+	for _, tt := range tests {
+		w, h := decorAdjustedSize(800, 600, tt.client, tt.frame)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%s: decorAdjustedSize(800, 600) = %dx%d, want %dx%d", tt.name, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestRequestedSize(t *testing.T) {
+	// 2px borders and a 24px title bar
+	client, frame := xrect.New(2, 24, 796, 574), xrect.New(100, 100, 800, 600)
+	tests := []struct {
+		name         string
+		adjust       bool
+		wantW, wantH int
+		wantLookups  int
+	}{
+		{"adjusted", true, 1276, 694, 1},
+		{"-no-decor-adjust", false, 1280, 720, 0},
+	}
+	for _, tt := range tests {
+		lookups := 0
+		adjust := func(width, height int) (int, int, error) {
+			lookups++
+			w, h := decorAdjustedSize(width, height, client, frame)
+			return w, h, nil
+		}
+		w, h, err := requestedSize(1280, 720, tt.adjust, adjust)
+		if err != nil || w != tt.wantW || h != tt.wantH {
+			t.Errorf("%s: requestedSize(1280, 720) = %dx%d, %v, want %dx%d", tt.name, w, h, err, tt.wantW, tt.wantH)
+		}
+		if lookups != tt.wantLookups {
+			t.Errorf("%s: looked up decorations %d times, want %d", tt.name, lookups, tt.wantLookups)
+		}
+	}
+}
@@ -0,0 +1,655 @@
+package gotomonitor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// Direction to search for the next screen
+type Ordinal int
+
+const (
+	North Ordinal = iota
+	South
+	East
+	West
+	NorthEast
+	NorthWest
+	SouthEast
+	SouthWest
+)
+
+// Unit vector components of a direction, with y increasing downwards like X11 screen coordinates
+func (dir Ordinal) components() (dx, dy int) {
+	switch dir {
+	case North:
+		return 0, -1
+	case South:
+		return 0, 1
+	case East:
+		return 1, 0
+	case West:
+		return -1, 0
+	case NorthEast:
+		return 1, -1
+	case NorthWest:
+		return -1, -1
+	case SouthEast:
+		return 1, 1
+	case SouthWest:
+		return -1, 1
+	}
+	return 0, 0
+}
+
+func (dir Ordinal) diagonal() bool {
+	dx, dy := dir.components()
+	return dx != 0 && dy != 0
+}
+
+// Axes along which FindNext wraps around from the last screen to the first
+type Wrap int
+
+const (
+	WrapHorizontal Wrap = 1 << iota
+	WrapVertical
+	WrapNone Wrap = 0
+	WrapAll  Wrap = WrapHorizontal | WrapVertical
+)
+
+// Whether moving in dir should wrap, diagonal moves only wrap when both axes do
+func (wrap Wrap) allows(dir Ordinal) bool {
+	dx, dy := dir.components()
+	return (dx == 0 || wrap&WrapHorizontal != 0) &&
+		(dy == 0 || wrap&WrapVertical != 0)
+}
+
+// Window geometry as fractions of the containing screen
+type RelativeGeometry struct {
+	X, Y, Width, Height float64
+}
+
+// Scales window geometry in integer units to fraction of screen in floating point units
+// This is a hack that sort of deals with monitors that are different sizes.
+// Moving a window that takes up a 1/4th of the screen to a monitor will resize the window to take 1/4 of the new monitor regardless of the actual monitor resolution and dimensions
+func BuildRelative(geo xrect.Rect, container xrect.Rect) RelativeGeometry {
+	return RelativeGeometry{
+		X:      float64(geo.X()-container.X()) / float64(container.Width()),
+		Y:      float64(geo.Y()-container.Y()) / float64(container.Height()),
+		Width:  float64(geo.Width()) / float64(container.Width()),
+		Height: float64(geo.Height()) / float64(container.Height()),
+	}
+}
+
+// Rounds each fraction to the nearest multiple of 1/denom, so a window lands on a clean grid of its new screen.
+// Sizes are never rounded down to nothing
+func SnapFraction(r RelativeGeometry, denom int) RelativeGeometry {
+	if denom <= 0 {
+		return r
+	}
+	d := float64(denom)
+	snap := func(f float64) float64 {
+		return math.Round(f*d) / d
+	}
+	snapped := RelativeGeometry{X: snap(r.X), Y: snap(r.Y), Width: snap(r.Width), Height: snap(r.Height)}
+	if snapped.Width <= 0 {
+		snapped.Width = 1 / d
+	}
+	if snapped.Height <= 0 {
+		snapped.Height = 1 / d
+	}
+	return snapped
+}
+
+// Inverse of BuildRelative, converts fractions of a screen back to integer window geometry
+func BuildAbsolute(rgeo RelativeGeometry, container xrect.Rect) xrect.Rect {
+	return xrect.New(
+		container.X()+int(math.Round(rgeo.X*float64(container.Width()))),
+		container.Y()+int(math.Round(rgeo.Y*float64(container.Height()))),
+		int(math.Round(rgeo.Width*float64(container.Width()))),
+		int(math.Round(rgeo.Height*float64(container.Height()))),
+	)
+}
+
+// Whether the rows of pixels covered by r and r2 have at least one row in common.
+// Screens placed edge to edge (one ends at y=1080, the next starts at y=1080) share no rows, so a screen that only
+// touches the current one at a corner is never treated as being beside it
+func overlaps_y(r xrect.Rect, r2 xrect.Rect) bool {
+	return spansOverlap(r.Y(), r.Height(), r2.Y(), r2.Height())
+}
+
+// Whether the columns of pixels covered by r and r2 have at least one column in common, see overlaps_y
+func overlaps_x(r xrect.Rect, r2 xrect.Rect) bool {
+	return spansOverlap(r.X(), r.Width(), r2.X(), r2.Width())
+}
+
+// Whether the half open spans [start, start+length) and [start2, start2+length2) share a pixel, empty spans share none
+func spansOverlap(start, length, start2, length2 int) bool {
+	return length > 0 && length2 > 0 && start2 < start+length && start2+length2 > start
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func center(r xrect.Rect) (x, y int) {
+	return r.X() + r.Width()/2, r.Y() + r.Height()/2
+}
+
+// Screens with the same geometry, regardless of whether they are the same value
+func SameRect(r xrect.Rect, r2 xrect.Rect) bool {
+	return r.X() == r2.X() && r.Y() == r2.Y() &&
+		r.Width() == r2.Width() && r.Height() == r2.Height()
+}
+
+// Scan list of screens to find the nearest screen whose center lies strictly within the quadrant given by a diagonal direction
+func findNextDiagonal(current int, screens []xrect.Rect, dir Ordinal, wrap bool) int {
+	curr := screens[current]
+	dx, dy := dir.components()
+	cx, cy := center(curr)
+
+	next := -1
+	best := math.MaxInt
+	// wrapping lands on the farthest screen in the opposite quadrant
+	wrapped := -1
+	farthest := -1
+
+	for j, r := range screens {
+		if j == current || SameRect(r, curr) {
+			continue
+		}
+		x, y := center(r)
+		dist := (x-cx)*(x-cx) + (y-cy)*(y-cy)
+		Debug.Printf("Considering monitor %d %v, center %d,%d", j, r, x, y)
+
+		if dx*(x-cx) > 0 && dy*(y-cy) > 0 && dist < best {
+			next = j
+			best = dist
+		}
+
+		if wrap && dx*(x-cx) < 0 && dy*(y-cy) < 0 && dist > farthest {
+			wrapped = j
+			farthest = dist
+		}
+	}
+
+	if wrap && next == -1 {
+		next = wrapped
+	}
+
+	if next == -1 {
+		next = current
+	}
+
+	return next
+}
+
+// Fraction of the shorter of a and b's extents along axis ('x' or 'y') that the two share
+func OverlapFraction(a, b xrect.Rect, axis rune) float64 {
+	start, length := xrect.Rect.X, xrect.Rect.Width
+	if axis == 'y' {
+		start, length = xrect.Rect.Y, xrect.Rect.Height
+	}
+
+	shortest := min(length(a), length(b))
+	if shortest <= 0 {
+		return 0
+	}
+	shared := min(start(a)+length(a), start(b)+length(b)) - max(start(a), start(b))
+	if shared <= 0 {
+		return 0
+	}
+	return float64(shared) / float64(shortest)
+}
+
+// Scan list of screens to find the index of the "next" screen in the given direction
+// Returns current if there is no screen in that direction
+func FindNext(current int, screens []xrect.Rect, dir Ordinal, wrapAxes Wrap) int {
+	return Search{Dir: dir, Wrap: wrapAxes}.Find(current, screens)
+}
+
+// Same as FindNext, but when several screens are equally far along the direction of travel
+// the one whose center is closest to geo's center on the perpendicular axis is chosen
+func FindNextNear(current int, screens []xrect.Rect, dir Ordinal, wrapAxes Wrap, geo xrect.Rect) int {
+	return Search{Dir: dir, Wrap: wrapAxes, Near: geo}.Find(current, screens)
+}
+
+// Parameters of a search for the next screen in a direction
+type Search struct {
+	Dir  Ordinal
+	Wrap Wrap
+	// Ties are broken in favour of the screen closest to Near, defaults to the current screen
+	Near xrect.Rect
+	// Fraction of the perpendicular axis a screen must share with the current screen to be considered, any overlap counts when 0
+	MinOverlap float64
+}
+
+// Index of the next screen after screens[current], or current if there is no screen in that direction
+func (s Search) Find(current int, screens []xrect.Rect) int {
+	dir := s.Dir
+	wrap := s.Wrap.allows(dir)
+	if dir.diagonal() {
+		return findNextDiagonal(current, screens, dir, wrap)
+	}
+
+	curr := screens[current]
+	geo := s.Near
+	if geo == nil {
+		geo = curr
+	}
+	gx, gy := center(geo)
+
+	i := 1
+	// invert search direction for west or north
+	if dir == West || dir == North {
+		i = -1
+	}
+
+	// east/west, search x axis
+	pos := xrect.Rect.X
+	// only consider screens that have overlaping y dimensions
+	overlaps := overlaps_y
+	axis := 'y'
+	// break ties on distance along y axis
+	perpendicular := func(r xrect.Rect) int {
+		_, y := center(r)
+		return abs(y - gy)
+	}
+
+	if dir == North || dir == South {
+		// north/south, search y-axis
+		pos = xrect.Rect.Y
+		// only consider screens that have overlaping x dimensions
+		overlaps = overlaps_x
+		axis = 'x'
+		perpendicular = func(r xrect.Rect) int {
+			x, _ := center(r)
+			return abs(x - gx)
+		}
+	}
+
+	// whether r comes before screens[best] in the direction of travel, nearest on the perpendicular axis first
+	before := func(r xrect.Rect, best int) bool {
+		if best == -1 {
+			return true
+		}
+		b := screens[best]
+		if pos(r) != pos(b) {
+			return i*pos(r) < i*pos(b)
+		}
+		return perpendicular(r) < perpendicular(b)
+	}
+
+	next := -1
+	global_min := -1
+
+	for j, r := range screens {
+		// skip curr (or a copy of it) and non-overlapping
+		if j == current || SameRect(r, curr) || !overlaps(r, curr) {
+			Debug.Printf("Skipping monitor %d %v, current or not overlapping", j, r)
+			continue
+		}
+		if s.MinOverlap > 0 && OverlapFraction(r, curr, axis) < s.MinOverlap {
+			Debug.Printf("Skipping monitor %d %v, overlaps less than %v", j, r, s.MinOverlap)
+			continue
+		}
+		Debug.Printf("Considering monitor %d %v", j, r)
+
+		// find first past curr
+		if i*pos(r) > i*pos(curr) && before(r, next) {
+			next = j
+		}
+
+		// find global miniumum (for wrapping support)
+		if wrap && before(r, global_min) {
+			global_min = j
+		}
+
+	}
+
+	if wrap && next == -1 {
+		next = global_min
+	}
+
+	if wrap && next == -1 && s.MinOverlap == 0 {
+		// No monitor shares the perpendicular axis with curr (e.g. monitors offset diagonally), rather than doing
+		// nothing fall back to the nearest other monitor in the direction of travel, wrapping to the farthest back.
+		// None of them overlap at all, so when a minimum overlap is asked for there is nothing to fall back to
+		past, first := -1, -1
+		for j, r := range screens {
+			if j == current || SameRect(r, curr) {
+				continue
+			}
+			if i*pos(r) > i*pos(curr) && before(r, past) {
+				past = j
+			}
+			if before(r, first) {
+				first = j
+			}
+		}
+		next = past
+		if next == -1 {
+			next = first
+		}
+		if next != -1 {
+			Debug.Printf("No overlapping monitor, falling back to monitor %d %v", next, screens[next])
+		}
+	}
+
+	if next == -1 {
+		next = current
+	}
+
+	return next
+
+}
+
+// Decide where a window on screens[current] should be placed to move it to screens[next], moved is false if the window stays put
+func PlanMove(geo xrect.Rect, screens []xrect.Rect, current, next int) (targetRect xrect.Rect, moved bool) {
+	if next == current {
+		return geo, false
+	}
+
+	return Scale(geo, screens[current], screens[next]), true
+}
+
+// Scale (if new screen is different size) and translate geo from src to dst
+func Scale(geo, src, dst xrect.Rect) xrect.Rect {
+	if src.Width() == dst.Width() && src.Height() == dst.Height() {
+		// Avoid any floating point drift between identically sized screens
+		return TranslateOnly(geo, src, dst)
+	}
+	return BuildAbsolute(BuildRelative(geo, src), dst)
+}
+
+// A monitor along with its scale factor, the number of physical pixels per logical pixel (2 on a HiDPI monitor at 2x)
+type ScaledRect struct {
+	xrect.Rect
+	Scale float64
+}
+
+// Scale factor, monitors without one are 1x
+func (r ScaledRect) factor() float64 {
+	if r.Scale <= 0 {
+		return 1
+	}
+	return r.Scale
+}
+
+// Logical size of a monitor, a 2x monitor is half as big as its pixel count suggests
+func (r ScaledRect) LogicalSize() (width, height float64) {
+	return float64(r.Width()) / r.factor(), float64(r.Height()) / r.factor()
+}
+
+// Like Scale, but between monitors with different scale factors the window keeps its size in logical pixels
+// rather than taking up the same fraction of the new monitor, so it looks the same size on both.
+// The position is still kept proportional, monitors with the same scale factor are handled exactly like Scale
+func ScaleDPI(geo xrect.Rect, src, dst ScaledRect) xrect.Rect {
+	if src.factor() == dst.factor() {
+		return Scale(geo, src.Rect, dst.Rect)
+	}
+
+	src_width, src_height := src.LogicalSize()
+	dst_width, dst_height := dst.LogicalSize()
+
+	rgeo := BuildRelative(geo, src.Rect)
+	// Window size in logical pixels as a fraction of the target monitor's logical size
+	rgeo.Width = rgeo.Width * src_width / dst_width
+	rgeo.Height = rgeo.Height * src_height / dst_height
+	return BuildAbsolute(rgeo, dst.Rect)
+}
+
+// Limits how much scaled, geo after scaling it to another monitor, grew by to at most maxScale times geo's size and
+// how much it shrank to no less than minScale times it, the same on both axes so it keeps its shape. Either limit
+// is ignored when 0. When one axis grew past maxScale while the other shrank past minScale (e.g. moving to a
+// portrait monitor) the floor wins, so the window stays usable.
+// A limited window is centered where scaled was, it may need clamping to fit its monitor
+func LimitScale(geo, scaled xrect.Rect, minScale, maxScale float64) xrect.Rect {
+	width := float64(geo.Width())
+	height := float64(geo.Height())
+	if width <= 0 || height <= 0 {
+		return scaled
+	}
+
+	grow_x := float64(scaled.Width()) / width
+	grow_y := float64(scaled.Height()) / height
+	var scale float64
+	switch {
+	case maxScale > 0 && (grow_x > maxScale || grow_y > maxScale):
+		scale = math.Max(minScale, math.Min(maxScale, math.Min(grow_x, grow_y)))
+		Debug.Printf("Capping growth of window at %v times", scale)
+	case minScale > 0 && (grow_x < minScale || grow_y < minScale):
+		scale = math.Max(minScale, math.Max(grow_x, grow_y))
+		Debug.Printf("Limiting shrinking of window to %v times", scale)
+	default:
+		return scaled
+	}
+
+	limited_width := int(math.Round(width * scale))
+	limited_height := int(math.Round(height * scale))
+	cx, cy := center(scaled)
+	return xrect.New(cx-limited_width/2, cy-limited_height/2, limited_width, limited_height)
+}
+
+// Like Scale, but the size changes by a whole multiple or divisor of the ratio between the monitors
+// (e.g. exactly doubling from 1920 to 3840 wide), so windows sized in character cells stay that way
+func IntegerScale(geo, src, dst xrect.Rect) xrect.Rect {
+	x := scaleInt(geo.X()-src.X(), src.Width(), dst.Width())
+	y := scaleInt(geo.Y()-src.Y(), src.Height(), dst.Height())
+	width := scaleInt(geo.Width(), src.Width(), dst.Width())
+	height := scaleInt(geo.Height(), src.Height(), dst.Height())
+	return xrect.New(dst.X()+x, dst.Y()+y, max(width, 1), max(height, 1))
+}
+
+// Scales v by the nearest whole multiple (or divisor when shrinking) of to/from
+func scaleInt(v, from, to int) int {
+	if from <= 0 || to <= 0 {
+		return v
+	}
+	if to >= from {
+		return v * int(math.Round(float64(to)/float64(from)))
+	}
+	return v / int(math.Round(float64(from)/float64(to)))
+}
+
+// Places geo margin pixels in from the edge of screen a window moving in dir enters through, e.g. the left edge
+// when moving East. Diagonal moves inset from both edges
+func EdgeInset(geo, screen xrect.Rect, dir Ordinal, margin int) xrect.Rect {
+	x, y, width, height := xrect.Pieces(geo)
+	dx, dy := dir.components()
+	switch dx {
+	case 1:
+		x = screen.X() + margin
+	case -1:
+		x = screen.X() + screen.Width() - width - margin
+	}
+	switch dy {
+	case 1:
+		y = screen.Y() + margin
+	case -1:
+		y = screen.Y() + screen.Height() - height - margin
+	}
+	return xrect.New(x, y, width, height)
+}
+
+// Shifts geo so it lies entirely within screen, only shrinking it if it is larger than the screen
+func ClampToScreen(geo xrect.Rect, screen xrect.Rect) xrect.Rect {
+	x, y, width, height := xrect.Pieces(geo)
+
+	if width > screen.Width() {
+		width = screen.Width()
+	}
+	if height > screen.Height() {
+		height = screen.Height()
+	}
+
+	if x+width > screen.X()+screen.Width() {
+		x = screen.X() + screen.Width() - width
+	}
+	if x < screen.X() {
+		x = screen.X()
+	}
+	if y+height > screen.Y()+screen.Height() {
+		y = screen.Y() + screen.Height() - height
+	}
+	if y < screen.Y() {
+		y = screen.Y()
+	}
+
+	return xrect.New(x, y, width, height)
+}
+
+// Moves geo from src to dst keeping its exact size and its pixel offset from the screen's top left corner
+func TranslateOnly(geo, src, dst xrect.Rect) xrect.Rect {
+	return xrect.New(
+		dst.X()+geo.X()-src.X(),
+		dst.Y()+geo.Y()-src.Y(),
+		geo.Width(),
+		geo.Height(),
+	)
+}
+
+// Position of a w by h window centered on screen
+func CenterOnScreen(w, h int, screen xrect.Rect) (x, y int) {
+	return screen.X() + (screen.Width()-w)/2, screen.Y() + (screen.Height()-h)/2
+}
+
+// Index following current when cycling through n screens, wrapping back to the first
+func NextCyclic(current int, n int) int {
+	if n <= 1 {
+		return current
+	}
+	return (current + 1) % n
+}
+
+// Indices of screens ordered left to right, then top to bottom
+func CycleOrder(screens []xrect.Rect) []int {
+	order := make([]int, len(screens))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := screens[order[a]], screens[order[b]]
+		if ra.X() != rb.X() {
+			return ra.X() < rb.X()
+		}
+		return ra.Y() < rb.Y()
+	})
+	return order
+}
+
+// Point the mouse pointer is warped to so it follows a window, the window's center
+func PointerTarget(geo xrect.Rect) (x, y int) {
+	return center(geo)
+}
+
+// Smallest rect containing both a and b
+func UnionRect(a, b xrect.Rect) xrect.Rect {
+	x := min(a.X(), b.X())
+	y := min(a.Y(), b.Y())
+	return xrect.New(x, y,
+		max(a.X()+a.Width(), b.X()+b.Width())-x,
+		max(a.Y()+a.Height(), b.Y()+b.Height())-y)
+}
+
+// The region a window is scaled relative to, normally the screen it is on, but for a window stretched across
+// several screens (wider or taller than the screen it is mostly on) it is the bounding box of the screens it covers
+// along that axis. A window that only straddles the edge between two screens still fits on one and is scaled
+// relative to it alone.
+// Returns nil only if there are no screens
+func SourceContainer(geo xrect.Rect, screens []xrect.Rect) xrect.Rect {
+	index := NearestScreen(geo, screens)
+	if index == -1 {
+		return nil
+	}
+	home := screens[index]
+	wide := geo.Width() > home.Width()
+	tall := geo.Height() > home.Height()
+
+	container := home
+	for i, r := range screens {
+		if i == index || xrect.IntersectArea(geo, r) == 0 {
+			continue
+		}
+		// Screens beside home for a wide window, above or below it for a tall one
+		if (wide && overlaps_y(r, home)) || (tall && overlaps_x(r, home)) {
+			container = UnionRect(container, r)
+		}
+	}
+	return container
+}
+
+// The screen geo overlaps most, or if it doesn't overlap any the screen whose center is nearest its center.
+// Only -1 if there are no screens
+func NearestScreen(geo xrect.Rect, screens []xrect.Rect) int {
+	index := xrect.LargestOverlap(geo, screens)
+	if index != -1 {
+		return index
+	}
+
+	gx, gy := center(geo)
+	best := -1
+	best_distance := 0
+	for i, r := range screens {
+		cx, cy := center(r)
+		dx, dy := cx-gx, cy-gy
+		distance := dx*dx + dy*dy
+		if best == -1 || distance < best_distance {
+			best, best_distance = i, distance
+		}
+	}
+	return best
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Region of screen a window is snapped to, one of the halves left, right, top, bottom, the quarters tl, tr, bl, br or full
+func SnapRect(region string, screen xrect.Rect) (xrect.Rect, error) {
+	x, y, w, h := xrect.Pieces(screen)
+	switch region {
+	case "left":
+		return xrect.New(x, y, w/2, h), nil
+	case "right":
+		return xrect.New(x+w/2, y, w-w/2, h), nil
+	case "top":
+		return xrect.New(x, y, w, h/2), nil
+	case "bottom":
+		return xrect.New(x, y+h/2, w, h-h/2), nil
+	case "full":
+		return xrect.New(x, y, w, h), nil
+	case "tl":
+		return xrect.New(x, y, w/2, h/2), nil
+	case "tr":
+		return xrect.New(x+w/2, y, w-w/2, h/2), nil
+	case "bl":
+		return xrect.New(x, y+h/2, w/2, h-h/2), nil
+	case "br":
+		return xrect.New(x+w/2, y+h/2, w-w/2, h-h/2), nil
+	default:
+		return nil, fmt.Errorf("unknown snap region %q, expected left/right/top/bottom/full/tl/tr/bl/br", region)
+	}
+}
+
+// Insets geo by gap pixels on every side, never shrinking it below 1x1
+func ApplyGap(geo xrect.Rect, gap int) xrect.Rect {
+	x, y, w, h := xrect.Pieces(geo)
+	return xrect.New(x+gap, y+gap, max(w-2*gap, 1), max(h-2*gap, 1))
+}
@@ -0,0 +1,848 @@
+package gotomonitor
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// Two 1920x1080 monitors side by side with a third below the left one
+func lShapedLayout() []xrect.Rect {
+	return []xrect.Rect{
+		xrect.New(0, 0, 1920, 1080),
+		xrect.New(1920, 0, 1920, 1080),
+		xrect.New(0, 1080, 1920, 1080),
+	}
+}
+
+func TestFindNextWrapAxes(t *testing.T) {
+	screens := lShapedLayout()
+	tests := []struct {
+		name    string
+		current int
+		dir     Ordinal
+		wrap    Wrap
+		want    int
+	}{
+		{"east wraps horizontally", 1, East, WrapHorizontal, 0},
+		{"south stays without vertical wrap", 2, South, WrapHorizontal, 2},
+		{"south wraps vertically", 2, South, WrapVertical, 0},
+		{"east stays without horizontal wrap", 1, East, WrapVertical, 1},
+		{"east wraps with all", 1, East, WrapAll, 0},
+		{"south wraps with all", 2, South, WrapAll, 0},
+		{"nothing wraps with none", 1, East, WrapNone, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindNext(tt.current, screens, tt.dir, tt.wrap)
+			if got != tt.want {
+				t.Errorf("FindNext(%d, %v, %v) = %d, want %d", tt.current, tt.dir, tt.wrap, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindNextDiagonallyOffset(t *testing.T) {
+	// The second monitor is below and to the right of the first, they share no rows or columns
+	screens := []xrect.Rect{
+		xrect.New(0, 0, 1920, 1080),
+		xrect.New(1920, 1080, 1920, 1080),
+	}
+	tests := []struct {
+		name       string
+		current    int
+		dir        Ordinal
+		wrap       Wrap
+		minOverlap float64
+		want       int
+	}{
+		{"east falls back to the monitor past it", 0, East, WrapAll, 0, 1},
+		{"east wraps back to the first", 1, East, WrapAll, 0, 0},
+		{"south falls back to the monitor past it", 0, South, WrapAll, 0, 1},
+		{"west falls back to the monitor past it", 1, West, WrapAll, 0, 0},
+		{"no fallback without wrapping", 0, East, WrapNone, 0, 0},
+		{"no fallback without wrapping on the axis", 0, East, WrapVertical, 0, 0},
+		{"no fallback with a minimum overlap", 0, East, WrapAll, 0.5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := Search{Dir: tt.dir, Wrap: tt.wrap, MinOverlap: tt.minOverlap}
+			got := search.Find(tt.current, screens)
+			if got != tt.want {
+				t.Errorf("Find(%d) moving %v = %d, want %d", tt.current, tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindNextMinOverlapSkipsSliver(t *testing.T) {
+	// Wide monitor with a small monitor sharing only a sliver of its width below it and a proper one below that
+	screens := []xrect.Rect{
+		xrect.New(0, 0, 1920, 1080),
+		xrect.New(1800, 1080, 1280, 1024),
+		xrect.New(0, 2104, 1920, 1080),
+	}
+	if got := (Search{Dir: South, Wrap: WrapAll}).Find(0, screens); got != 1 {
+		t.Errorf("Find without a minimum overlap = %d, want the sliver 1", got)
+	}
+	if got := (Search{Dir: South, Wrap: WrapAll, MinOverlap: 0.5}).Find(0, screens); got != 2 {
+		t.Errorf("Find with a minimum overlap = %d, want 2", got)
+	}
+}
+
+// cols by rows monitors of w by h pixels laid out edge to edge, numbered row by row from the top left
+func grid(cols, rows, w, h int) []xrect.Rect {
+	screens := make([]xrect.Rect, 0, cols*rows)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			screens = append(screens, xrect.New(col*w, row*h, w, h))
+		}
+	}
+	return screens
+}
+
+type gridMove struct {
+	row, col int
+	dir      Ordinal
+	wantRow  int
+	wantCol  int
+}
+
+// Moves from the middle of an n by n grid and off each of its edges, wrapping on both axes
+func gridMoves(n int) []gridMove {
+	mid, last := n/2, n-1
+	return []gridMove{
+		{mid, mid, East, mid, mid + 1},
+		{mid, mid, West, mid, mid - 1},
+		{mid, mid, North, mid - 1, mid},
+		{mid, mid, South, mid + 1, mid},
+		{mid, mid, SouthEast, mid + 1, mid + 1},
+		{mid, mid, NorthWest, mid - 1, mid - 1},
+		{mid, last, East, mid, 0},
+		{mid, 0, West, mid, last},
+		{last, mid, South, 0, mid},
+		{0, mid, North, last, mid},
+	}
+}
+
+var gridSizes = []int{3, 4, 5}
+
+func TestFindNextGrid(t *testing.T) {
+	for _, n := range gridSizes {
+		screens := grid(n, n, 1920, 1080)
+		for _, m := range gridMoves(n) {
+			got := FindNext(m.row*n+m.col, screens, m.dir, WrapAll)
+			if want := m.wantRow*n + m.wantCol; got != want {
+				t.Errorf("%dx%d: FindNext from (%d,%d) moving %v = %d, want %d", n, n, m.row, m.col, m.dir, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkFindNext(b *testing.B) {
+	for _, n := range gridSizes {
+		screens := grid(n, n, 1920, 1080)
+		moves := gridMoves(n)
+		b.Run(fmt.Sprintf("%d heads", n*n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m := moves[i%len(moves)]
+				got := FindNext(m.row*n+m.col, screens, m.dir, WrapAll)
+				if want := m.wantRow*n + m.wantCol; got != want {
+					b.Fatalf("FindNext from (%d,%d) moving %v = %d, want %d", m.row, m.col, m.dir, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSourceContainer(t *testing.T) {
+	screens := lShapedLayout()
+	tests := []struct {
+		name string
+		geo  xrect.Rect
+		want xrect.Rect
+	}{
+		{"on one monitor", xrect.New(100, 100, 800, 600), screens[0]},
+		{"mostly on one monitor", xrect.New(1800, 100, 800, 600), screens[1]},
+		{"stretched across two", xrect.New(0, 0, 3840, 1080), UnionRect(screens[0], screens[1])},
+		{"off screen to the right", xrect.New(5000, 100, 800, 600), screens[1]},
+		{"off screen below", xrect.New(100, 4000, 800, 600), screens[2]},
+	}
+	for _, tt := range tests {
+		got := SourceContainer(tt.geo, screens)
+		if got == nil || !SameRect(got, tt.want) {
+			t.Errorf("%s: SourceContainer(%v) = %v, want %v", tt.name, tt.geo, got, tt.want)
+		}
+	}
+
+	if got := SourceContainer(xrect.New(0, 0, 800, 600), nil); got != nil {
+		t.Errorf("SourceContainer with no screens = %v, want nil", got)
+	}
+}
+
+func TestNearestScreen(t *testing.T) {
+	screens := lShapedLayout()
+	tests := []struct {
+		name string
+		geo  xrect.Rect
+		want int
+	}{
+		{"fully on a monitor", xrect.New(100, 100, 800, 600), 0},
+		{"overlapping most", xrect.New(1800, 100, 800, 600), 1},
+		{"off screen to the right", xrect.New(5000, 100, 800, 600), 1},
+		{"off screen above", xrect.New(100, -2000, 800, 600), 0},
+		{"off screen below", xrect.New(100, 2500, 800, 600), 2},
+	}
+	for _, tt := range tests {
+		if got := NearestScreen(tt.geo, screens); got != tt.want {
+			t.Errorf("%s: NearestScreen(%v) = %d, want %d", tt.name, tt.geo, got, tt.want)
+		}
+	}
+	// Floating in the gap between two monitors, nearer the centre of the right one
+	apart := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(2420, 0, 1920, 1080)}
+	if got := NearestScreen(xrect.New(2010, 100, 400, 300), apart); got != 1 {
+		t.Errorf("NearestScreen between monitors = %d, want 1", got)
+	}
+	if got := NearestScreen(xrect.New(0, 0, 800, 600), nil); got != -1 {
+		t.Errorf("NearestScreen with no screens = %d, want -1", got)
+	}
+}
+
+func TestLimitScale(t *testing.T) {
+	geo := xrect.New(100, 100, 400, 300)
+	tests := []struct {
+		name               string
+		scaled             xrect.Rect
+		minScale, maxScale float64
+		want               xrect.Rect
+	}{
+		{"no limits", xrect.New(0, 0, 1600, 1200), 0, 0, xrect.New(0, 0, 1600, 1200)},
+		{"growth under the cap", xrect.New(0, 0, 560, 420), 0, 1.5, xrect.New(0, 0, 560, 420)},
+		{"growth capped", xrect.New(0, 0, 800, 600), 0, 1.5, xrect.New(100, 75, 600, 450)},
+		{"uneven growth capped", xrect.New(0, 0, 800, 400), 0, 1.5, xrect.New(134, 0, 533, 400)},
+		{"shrinking over the floor", xrect.New(0, 0, 300, 225), 0.5, 0, xrect.New(0, 0, 300, 225)},
+		{"shrinking floored", xrect.New(100, 100, 100, 75), 0.5, 0, xrect.New(50, 62, 200, 150)},
+	}
+	for _, tt := range tests {
+		got := LimitScale(geo, tt.scaled, tt.minScale, tt.maxScale)
+		if !SameRect(got, tt.want) {
+			t.Errorf("%s: LimitScale(%v, %v, %v, %v) = %v, want %v", tt.name, geo, tt.scaled, tt.minScale, tt.maxScale, got, tt.want)
+		}
+	}
+}
+
+func TestLimitScaleAfterScale(t *testing.T) {
+	src := xrect.New(0, 0, 1920, 1080)
+	dst := xrect.New(1920, 0, 3840, 2160)
+	// A quarter of the monitor, in its top left corner, doubles in size on the larger monitor
+	geo := xrect.New(0, 0, 960, 540)
+	scaled := Scale(geo, src, dst)
+	if got, want := LimitScale(geo, scaled, 0, 0), xrect.New(1920, 0, 1920, 1080); !SameRect(got, want) {
+		t.Errorf("LimitScale without a cap = %v, want %v", got, want)
+	}
+	// Centered where the doubled window would have been
+	if got, want := LimitScale(geo, scaled, 0, 1.5), xrect.New(2160, 135, 1440, 810); !SameRect(got, want) {
+		t.Errorf("LimitScale capped at 1.5 = %v, want %v", got, want)
+	}
+}
+
+func TestLimitScaleConflicting(t *testing.T) {
+	// Moving from a landscape monitor to a portrait one, the window gets taller but much narrower
+	src := xrect.New(0, 0, 1920, 1080)
+	dst := xrect.New(1920, 0, 1080, 1920)
+	geo := xrect.New(160, 90, 1600, 900)
+	scaled := Scale(geo, src, dst)
+
+	got := LimitScale(geo, scaled, 0.75, 1.5)
+	if want := xrect.New(scaled.X()+scaled.Width()/2-600, scaled.Y()+scaled.Height()/2-337, 1200, 675); !SameRect(got, want) {
+		t.Errorf("LimitScale(%v, %v, 0.75, 1.5) = %v, want the floor %v", geo, scaled, got, want)
+	}
+
+	// Too wide for the portrait monitor at the floor, so it is clamped to fit
+	clamped := ClampToScreen(got, dst)
+	if clamped.X() < dst.X() || clamped.X()+clamped.Width() > dst.X()+dst.Width() ||
+		clamped.Y() < dst.Y() || clamped.Y()+clamped.Height() > dst.Y()+dst.Height() {
+		t.Errorf("ClampToScreen(%v, %v) = %v, not within the monitor", got, dst, clamped)
+	}
+	if clamped.Height() != 675 {
+		t.Errorf("ClampToScreen(%v, %v) = %v, want the floored height 675 kept", got, dst, clamped)
+	}
+}
+
+func TestFindNextDiagonal(t *testing.T) {
+	// 0 1
+	// 2 3
+	screens := grid(2, 2, 1920, 1080)
+	tests := []struct {
+		current int
+		dir     Ordinal
+		want    int
+	}{
+		{0, SouthEast, 3},
+		{3, NorthWest, 0},
+		{1, SouthWest, 2},
+		{2, NorthEast, 1},
+		// Nothing in the quadrant
+		{0, NorthEast, 0},
+		{0, SouthWest, 0},
+		{3, SouthEast, 3},
+		// Orthogonal moves are unchanged
+		{0, East, 1},
+		{0, South, 2},
+		{3, West, 2},
+		{3, North, 1},
+	}
+	for _, tt := range tests {
+		if got := FindNext(tt.current, screens, tt.dir, WrapNone); got != tt.want {
+			t.Errorf("FindNext(%d, %v) = %d, want %d", tt.current, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestPlanMove(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 3840, 2160)}
+	geo := xrect.New(480, 270, 960, 540)
+
+	got, moved := PlanMove(geo, screens, 0, 1)
+	if want := xrect.New(2880, 540, 1920, 1080); !moved || !SameRect(got, want) {
+		t.Errorf("PlanMove(%v, 0, 1) = %v %v, want %v true", geo, got, moved, want)
+	}
+
+	got, moved = PlanMove(geo, screens, 0, 0)
+	if moved || !SameRect(got, geo) {
+		t.Errorf("PlanMove(%v, 0, 0) = %v %v, want %v false", geo, got, moved, geo)
+	}
+}
+
+func TestBuildRelative(t *testing.T) {
+	screen := xrect.New(1920, 0, 1920, 1080)
+	got := BuildRelative(xrect.New(2400, 270, 960, 540), screen)
+	want := RelativeGeometry{X: 0.25, Y: 0.25, Width: 0.5, Height: 0.5}
+	if got != want {
+		t.Errorf("BuildRelative = %+v, want %+v", got, want)
+	}
+
+	if abs := BuildAbsolute(want, xrect.New(0, 1080, 2560, 1440)); !SameRect(abs, xrect.New(640, 1440, 1280, 720)) {
+		t.Errorf("BuildAbsolute(%+v) = %v, want [(640, 1440) 1280x720]", want, abs)
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name     string
+		geo      xrect.Rect
+		src, dst xrect.Rect
+		want     xrect.Rect
+	}{
+		{"same size", xrect.New(100, 50, 800, 600), xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080), xrect.New(2020, 50, 800, 600)},
+		{"twice the size", xrect.New(100, 50, 800, 600), xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 3840, 2160), xrect.New(2120, 100, 1600, 1200)},
+		{"half the size", xrect.New(3840, 1080, 1920, 1080), xrect.New(1920, 0, 3840, 2160), xrect.New(0, 0, 1920, 1080), xrect.New(960, 540, 960, 540)},
+	}
+	for _, tt := range tests {
+		if got := Scale(tt.geo, tt.src, tt.dst); !SameRect(got, tt.want) {
+			t.Errorf("%s: Scale(%v, %v, %v) = %v, want %v", tt.name, tt.geo, tt.src, tt.dst, got, tt.want)
+		}
+	}
+}
+
+func TestSameRect(t *testing.T) {
+	r := xrect.New(0, 0, 1920, 1080)
+	if !SameRect(r, xrect.New(0, 0, 1920, 1080)) {
+		t.Errorf("SameRect of equal rects = false")
+	}
+	for _, other := range []xrect.Rect{xrect.New(1, 0, 1920, 1080), xrect.New(0, 1, 1920, 1080), xrect.New(0, 0, 1919, 1080), xrect.New(0, 0, 1920, 1081)} {
+		if SameRect(r, other) {
+			t.Errorf("SameRect(%v, %v) = true", r, other)
+		}
+	}
+}
+
+func TestOrdinalComponents(t *testing.T) {
+	tests := []struct {
+		dir      Ordinal
+		dx, dy   int
+		diagonal bool
+	}{
+		{North, 0, -1, false},
+		{South, 0, 1, false},
+		{East, 1, 0, false},
+		{West, -1, 0, false},
+		{NorthEast, 1, -1, true},
+		{NorthWest, -1, -1, true},
+		{SouthEast, 1, 1, true},
+		{SouthWest, -1, 1, true},
+	}
+	for _, tt := range tests {
+		dx, dy := tt.dir.components()
+		if dx != tt.dx || dy != tt.dy || tt.dir.diagonal() != tt.diagonal {
+			t.Errorf("Ordinal %d = (%d, %d) diagonal %v, want (%d, %d) diagonal %v",
+				tt.dir, dx, dy, tt.dir.diagonal(), tt.dx, tt.dy, tt.diagonal)
+		}
+	}
+}
+
+func TestClampToScreen(t *testing.T) {
+	screen := xrect.New(1920, 0, 1920, 1080)
+	tests := []struct {
+		name string
+		geo  xrect.Rect
+		want xrect.Rect
+	}{
+		{"already on screen", xrect.New(2000, 100, 800, 600), xrect.New(2000, 100, 800, 600)},
+		{"overflowing the right edge", xrect.New(3500, 100, 800, 600), xrect.New(3040, 100, 800, 600)},
+		{"overflowing the bottom edge", xrect.New(2000, 700, 800, 600), xrect.New(2000, 480, 800, 600)},
+		{"off the top left", xrect.New(1800, -50, 800, 600), xrect.New(1920, 0, 800, 600)},
+		{"larger than the screen", xrect.New(1900, -10, 2560, 1440), xrect.New(1920, 0, 1920, 1080)},
+		{"too wide only", xrect.New(2000, 100, 2560, 600), xrect.New(1920, 100, 1920, 600)},
+	}
+	for _, tt := range tests {
+		if got := ClampToScreen(tt.geo, screen); !SameRect(got, tt.want) {
+			t.Errorf("%s: ClampToScreen(%v) = %v, want %v", tt.name, tt.geo, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateOnly(t *testing.T) {
+	geo := xrect.New(137, 91, 813, 601)
+	// Identically sized monitors keep the exact size and offset
+	got := TranslateOnly(geo, xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080))
+	if want := xrect.New(2057, 91, 813, 601); !SameRect(got, want) {
+		t.Errorf("TranslateOnly between identical monitors = %v, want %v", got, want)
+	}
+	// Only the position moves on a bigger monitor
+	got = TranslateOnly(geo, xrect.New(0, 0, 1920, 1080), xrect.New(-2560, 200, 2560, 1440))
+	if want := xrect.New(-2423, 291, 813, 601); !SameRect(got, want) {
+		t.Errorf("TranslateOnly to a bigger monitor = %v, want %v", got, want)
+	}
+}
+
+func TestCenterOnScreen(t *testing.T) {
+	screen := xrect.New(1920, 0, 1920, 1080)
+	tests := []struct {
+		name         string
+		w, h         int
+		wantX, wantY int
+	}{
+		{"smaller than the screen", 800, 600, 2480, 240},
+		{"the size of the screen", 1920, 1080, 1920, 0},
+		{"larger than the screen", 2560, 1440, 1600, -180},
+	}
+	for _, tt := range tests {
+		x, y := CenterOnScreen(tt.w, tt.h, screen)
+		if x != tt.wantX || y != tt.wantY {
+			t.Errorf("%s: CenterOnScreen(%d, %d) = %d, %d, want %d, %d", tt.name, tt.w, tt.h, x, y, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+func TestScaleRoundTripDrift(t *testing.T) {
+	const trips = 50
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name    string
+		a, b    xrect.Rect
+		maxDiff int
+	}{
+		{"different sizes", xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 2560, 1440), 1},
+		{"odd sizes", xrect.New(0, 0, 1366, 768), xrect.New(1366, 0, 1280, 1024), 1},
+		{"same size", xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080), 0},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			w := 1 + rng.Intn(tt.a.Width())
+			h := 1 + rng.Intn(tt.a.Height())
+			original := xrect.New(rng.Intn(tt.a.Width()-w+1), rng.Intn(tt.a.Height()-h+1), w, h)
+
+			var geo xrect.Rect = original
+			for trip := 0; trip < trips; trip++ {
+				geo = Scale(Scale(geo, tt.a, tt.b), tt.b, tt.a)
+			}
+			if abs(geo.X()-original.X()) > tt.maxDiff || abs(geo.Y()-original.Y()) > tt.maxDiff ||
+				abs(geo.Width()-original.Width()) > tt.maxDiff || abs(geo.Height()-original.Height()) > tt.maxDiff {
+				t.Errorf("%s: %v moved back and forth %d times = %v, want within %dpx", tt.name, original, trips, geo, tt.maxDiff)
+			}
+		}
+	}
+}
+
+func TestFindNextByIndex(t *testing.T) {
+	screens := lShapedLayout()
+	// Equal in value to screens but separate values, as after re-querying the monitors
+	fresh := lShapedLayout()
+	if screens[0] == fresh[0] || !SameRect(screens[0], fresh[0]) {
+		t.Fatalf("want %v and %v distinct but the same geometry", screens[0], fresh[0])
+	}
+
+	tests := []struct {
+		current int
+		dir     Ordinal
+		want    int
+	}{
+		{0, East, 1},
+		{1, West, 0},
+		{0, South, 2},
+		// The current screen is never its own next screen
+		{0, West, 0},
+		{1, North, 1},
+	}
+	for _, tt := range tests {
+		if got := FindNext(tt.current, fresh, tt.dir, WrapNone); got != tt.want {
+			t.Errorf("FindNext(%d, %v) = %d, want %d", tt.current, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestNextCyclic(t *testing.T) {
+	tests := []struct {
+		current, n int
+		want       int
+	}{
+		{0, 3, 1},
+		{1, 3, 2},
+		{2, 3, 0},
+		// A single monitor has nowhere to cycle to
+		{0, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := NextCyclic(tt.current, tt.n); got != tt.want {
+			t.Errorf("NextCyclic(%d, %d) = %d, want %d", tt.current, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestCycleOrder(t *testing.T) {
+	screens := []xrect.Rect{
+		xrect.New(1920, 0, 1920, 1080),
+		xrect.New(0, 1080, 1920, 1080),
+		xrect.New(0, 0, 1920, 1080),
+	}
+	want := []int{2, 1, 0}
+	if got := CycleOrder(screens); !reflect.DeepEqual(got, want) {
+		t.Errorf("CycleOrder(%v) = %v, want %v", screens, got, want)
+	}
+}
+
+func TestPointerTarget(t *testing.T) {
+	tests := []struct {
+		geo          xrect.Rect
+		wantX, wantY int
+	}{
+		{xrect.New(0, 0, 1920, 1080), 960, 540},
+		{xrect.New(1920, 100, 801, 601), 2320, 400},
+		{xrect.New(-1280, 0, 1280, 1024), -640, 512},
+	}
+	for _, tt := range tests {
+		x, y := PointerTarget(tt.geo)
+		if x != tt.wantX || y != tt.wantY {
+			t.Errorf("PointerTarget(%v) = %d, %d, want %d, %d", tt.geo, x, y, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+func TestFindNextNearTieBreak(t *testing.T) {
+	// A tall monitor with a column of two monitors east of it, both equally far along the direction of travel
+	screens := []xrect.Rect{
+		xrect.New(0, 0, 1920, 2160),
+		xrect.New(1920, 0, 1920, 1080),
+		xrect.New(1920, 1080, 1920, 1080),
+	}
+	tests := []struct {
+		name string
+		geo  xrect.Rect
+		want int
+	}{
+		{"window near the top", xrect.New(100, 100, 800, 600), 1},
+		{"window near the bottom", xrect.New(100, 1500, 800, 600), 2},
+	}
+	for _, tt := range tests {
+		if got := FindNextNear(0, screens, East, WrapNone, tt.geo); got != tt.want {
+			t.Errorf("%s: FindNextNear(%v) = %d, want %d", tt.name, tt.geo, got, tt.want)
+		}
+	}
+}
+
+func TestSourceContainerSpans(t *testing.T) {
+	screens := grid(3, 1, 1920, 1080)
+	tests := []struct {
+		name string
+		geo  xrect.Rect
+		want xrect.Rect
+	}{
+		{"a sliver over the edge is ignored", xrect.New(1000, 0, 1000, 1080), screens[0]},
+		// Half on each monitor, but no wider than either
+		{"straddling two", xrect.New(1070, 100, 1000, 600), screens[0]},
+		{"across two", xrect.New(1000, 0, 2400, 1080), xrect.New(0, 0, 3840, 1080)},
+		{"across all three", xrect.New(1000, 0, 3840, 1080), xrect.New(0, 0, 5760, 1080)},
+	}
+	for _, tt := range tests {
+		got := SourceContainer(tt.geo, screens)
+		if !SameRect(got, tt.want) {
+			t.Errorf("%s: SourceContainer(%v) = %v, want %v", tt.name, tt.geo, got, tt.want)
+		}
+	}
+}
+
+func TestSnapRectHalves(t *testing.T) {
+	screen := xrect.New(1920, 0, 1920, 1080)
+	tests := []struct {
+		region string
+		want   xrect.Rect
+	}{
+		{"left", xrect.New(1920, 0, 960, 1080)},
+		{"right", xrect.New(2880, 0, 960, 1080)},
+		{"top", xrect.New(1920, 0, 1920, 540)},
+		{"bottom", xrect.New(1920, 540, 1920, 540)},
+		{"full", xrect.New(1920, 0, 1920, 1080)},
+	}
+	for _, tt := range tests {
+		got, err := SnapRect(tt.region, screen)
+		if err != nil {
+			t.Errorf("SnapRect(%q) error: %v", tt.region, err)
+		} else if !SameRect(got, tt.want) {
+			t.Errorf("SnapRect(%q) = %v, want %v", tt.region, got, tt.want)
+		}
+	}
+
+	_, err := SnapRect("middle", screen)
+	if err == nil {
+		t.Errorf("SnapRect(%q) accepted an unknown region", "middle")
+	}
+}
+
+func TestSnapRectQuarters(t *testing.T) {
+	tests := []struct {
+		region string
+		screen xrect.Rect
+		want   xrect.Rect
+	}{
+		{"tl", xrect.New(0, 0, 1920, 1080), xrect.New(0, 0, 960, 540)},
+		{"tr", xrect.New(0, 0, 1920, 1080), xrect.New(960, 0, 960, 540)},
+		{"bl", xrect.New(0, 0, 1920, 1080), xrect.New(0, 540, 960, 540)},
+		{"br", xrect.New(0, 0, 1920, 1080), xrect.New(960, 540, 960, 540)},
+		// Odd sizes, the right and bottom quarters take the extra pixel so the quarters still cover the screen
+		{"tl", xrect.New(100, 50, 1365, 767), xrect.New(100, 50, 682, 383)},
+		{"tr", xrect.New(100, 50, 1365, 767), xrect.New(782, 50, 683, 383)},
+		{"bl", xrect.New(100, 50, 1365, 767), xrect.New(100, 433, 682, 384)},
+		{"br", xrect.New(100, 50, 1365, 767), xrect.New(782, 433, 683, 384)},
+	}
+	for _, tt := range tests {
+		got, err := SnapRect(tt.region, tt.screen)
+		if err != nil {
+			t.Errorf("SnapRect(%q, %v) error: %v", tt.region, tt.screen, err)
+		} else if !SameRect(got, tt.want) {
+			t.Errorf("SnapRect(%q, %v) = %v, want %v", tt.region, tt.screen, got, tt.want)
+		}
+	}
+}
+
+func TestApplyGap(t *testing.T) {
+	geo := xrect.New(100, 100, 800, 600)
+	tests := []struct {
+		name string
+		gap  int
+		want xrect.Rect
+	}{
+		{"no gap", 0, xrect.New(100, 100, 800, 600)},
+		{"normal gap", 8, xrect.New(108, 108, 784, 584)},
+		{"gap larger than the window", 500, xrect.New(600, 600, 1, 1)},
+	}
+	for _, tt := range tests {
+		if got := ApplyGap(geo, tt.gap); !SameRect(got, tt.want) {
+			t.Errorf("%s: ApplyGap(%v, %d) = %v, want %v", tt.name, geo, tt.gap, got, tt.want)
+		}
+	}
+}
+
+func TestOverlapFraction(t *testing.T) {
+	landscape := xrect.New(0, 0, 1920, 1080)
+	tests := []struct {
+		name string
+		b    xrect.Rect
+		axis rune
+		want float64
+	}{
+		{"full overlap", xrect.New(0, 1080, 1920, 1080), 'x', 1},
+		{"portrait monitor below", xrect.New(0, 1080, 1080, 1920), 'x', 1},
+		{"partial overlap", xrect.New(1440, 1080, 960, 1080), 'x', 0.5},
+		{"partial overlap on y", xrect.New(1920, 540, 1920, 1080), 'y', 0.5},
+		{"edge to edge", xrect.New(1920, 1080, 1920, 1080), 'x', 0},
+		{"no overlap", xrect.New(3000, 1080, 1920, 1080), 'x', 0},
+	}
+	for _, tt := range tests {
+		if got := OverlapFraction(landscape, tt.b, tt.axis); got != tt.want {
+			t.Errorf("%s: OverlapFraction(%v, %v, %c) = %v, want %v", tt.name, landscape, tt.b, tt.axis, got, tt.want)
+		}
+	}
+}
+
+func TestIntegerScale(t *testing.T) {
+	hd := xrect.New(0, 0, 1920, 1080)
+	uhd := xrect.New(1920, 0, 3840, 2160)
+	tests := []struct {
+		name          string
+		geo, src, dst xrect.Rect
+		want          xrect.Rect
+	}{
+		{"doubling", xrect.New(100, 50, 800, 600), hd, uhd, xrect.New(2120, 100, 1600, 1200)},
+		{"halving", xrect.New(2120, 100, 1600, 1200), uhd, hd, xrect.New(100, 50, 800, 600)},
+		{"same size", xrect.New(100, 50, 800, 600), hd, xrect.New(1920, 0, 1920, 1080), xrect.New(2020, 50, 800, 600)},
+		// 2560/1920 rounds to a multiple of 1, so the size is kept
+		{"not a multiple", xrect.New(0, 0, 801, 601), hd, xrect.New(1920, 0, 2560, 1440), xrect.New(1920, 0, 801, 601)},
+	}
+	for _, tt := range tests {
+		got := IntegerScale(tt.geo, tt.src, tt.dst)
+		if !SameRect(got, tt.want) {
+			t.Errorf("%s: IntegerScale(%v, %v, %v) = %v, want %v", tt.name, tt.geo, tt.src, tt.dst, got, tt.want)
+		}
+	}
+}
+
+func TestScaledRectLogicalSize(t *testing.T) {
+	tests := []struct {
+		r            ScaledRect
+		wantW, wantH float64
+	}{
+		{ScaledRect{xrect.New(0, 0, 3840, 2160), 2}, 1920, 1080},
+		{ScaledRect{xrect.New(0, 0, 1920, 1080), 1}, 1920, 1080},
+		{ScaledRect{xrect.New(0, 0, 1920, 1080), 0}, 1920, 1080},
+	}
+	for _, tt := range tests {
+		w, h := tt.r.LogicalSize()
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%v at %vx LogicalSize() = %vx%v, want %vx%v", tt.r.Rect, tt.r.Scale, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestScaleDPI(t *testing.T) {
+	hd := ScaledRect{xrect.New(0, 0, 1920, 1080), 1}
+	uhd := ScaledRect{xrect.New(1920, 0, 3840, 2160), 2}
+	qhd := ScaledRect{xrect.New(1920, 0, 2560, 1440), 1}
+	tests := []struct {
+		name     string
+		geo      xrect.Rect
+		src, dst ScaledRect
+		want     xrect.Rect
+	}{
+		// Both monitors are 1920x1080 logical pixels, so the window is twice as many pixels on the 2x monitor
+		{"onto 2x", xrect.New(480, 270, 960, 540), hd, uhd, xrect.New(2880, 540, 1920, 1080)},
+		{"off 2x", xrect.New(2880, 540, 1920, 1080), uhd, hd, xrect.New(480, 270, 960, 540)},
+		{"same scale", xrect.New(480, 270, 960, 540), hd, qhd, Scale(xrect.New(480, 270, 960, 540), hd.Rect, qhd.Rect)},
+	}
+	for _, tt := range tests {
+		got := ScaleDPI(tt.geo, tt.src, tt.dst)
+		if !SameRect(got, tt.want) {
+			t.Errorf("%s: ScaleDPI(%v) = %v, want %v", tt.name, tt.geo, got, tt.want)
+		}
+	}
+}
+
+func TestEdgeInset(t *testing.T) {
+	screen := xrect.New(1920, 0, 1920, 1080)
+	geo := xrect.New(2400, 200, 800, 600)
+	tests := []struct {
+		dir  Ordinal
+		want xrect.Rect
+	}{
+		// Entering through the left edge
+		{East, xrect.New(1930, 200, 800, 600)},
+		{West, xrect.New(3030, 200, 800, 600)},
+		{South, xrect.New(2400, 10, 800, 600)},
+		{North, xrect.New(2400, 470, 800, 600)},
+		{NorthEast, xrect.New(1930, 470, 800, 600)},
+		{SouthWest, xrect.New(3030, 10, 800, 600)},
+	}
+	for _, tt := range tests {
+		if got := EdgeInset(geo, screen, tt.dir, 10); !SameRect(got, tt.want) {
+			t.Errorf("EdgeInset(%v, %v) = %v, want %v", geo, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestSnapFraction(t *testing.T) {
+	r := RelativeGeometry{X: 0.3, Y: 0.1, Width: 0.45, Height: 0.05}
+	tests := []struct {
+		denom int
+		want  RelativeGeometry
+	}{
+		// Heights that round to nothing become one step
+		{2, RelativeGeometry{X: 0.5, Y: 0, Width: 0.5, Height: 0.5}},
+		{3, RelativeGeometry{X: 1.0 / 3, Y: 0, Width: 1.0 / 3, Height: 1.0 / 3}},
+		{4, RelativeGeometry{X: 0.25, Y: 0, Width: 0.5, Height: 0.25}},
+		{0, r},
+	}
+	for _, tt := range tests {
+		if got := SnapFraction(r, tt.denom); got != tt.want {
+			t.Errorf("SnapFraction(%+v, %d) = %+v, want %+v", r, tt.denom, got, tt.want)
+		}
+	}
+}
+
+func TestOverlapsEdges(t *testing.T) {
+	left := xrect.New(0, 0, 1920, 1080)
+	tests := []struct {
+		name  string
+		other xrect.Rect
+		want  bool
+	}{
+		{"exactly touching", xrect.New(1920, 0, 1920, 1080), false},
+		{"1px overlapping", xrect.New(1919, 0, 1920, 1080), true},
+		{"1px gap", xrect.New(1921, 0, 1920, 1080), false},
+		{"same columns", xrect.New(0, 1080, 1920, 1080), true},
+		{"zero width", xrect.New(100, 0, 0, 1080), false},
+	}
+	for _, tt := range tests {
+		if got := overlaps_x(left, tt.other); got != tt.want {
+			t.Errorf("%s: overlaps_x(%v, %v) = %v, want %v", tt.name, left, tt.other, got, tt.want)
+		}
+		if got := overlaps_x(tt.other, left); got != tt.want {
+			t.Errorf("%s: overlaps_x(%v, %v) = %v, want %v", tt.name, tt.other, left, got, tt.want)
+		}
+		// The same pair turned on its side
+		a, b := xrect.New(left.Y(), left.X(), left.Height(), left.Width()),
+			xrect.New(tt.other.Y(), tt.other.X(), tt.other.Height(), tt.other.Width())
+		if got := overlaps_y(a, b); got != tt.want {
+			t.Errorf("%s: overlaps_y(%v, %v) = %v, want %v", tt.name, a, b, got, tt.want)
+		}
+	}
+}
+
+func TestUnionRect(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b xrect.Rect
+		want xrect.Rect
+	}{
+		{"side by side", xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080), xrect.New(0, 0, 3840, 1080)},
+		{"stacked", xrect.New(0, 1080, 1920, 1080), xrect.New(0, 0, 1920, 1080), xrect.New(0, 0, 1920, 2160)},
+		// Different heights, the union is their bounding box
+		{"unaligned", xrect.New(0, 200, 1920, 1080), xrect.New(1920, 0, 2560, 1440), xrect.New(0, 0, 4480, 1440)},
+	}
+	for _, tt := range tests {
+		if got := UnionRect(tt.a, tt.b); !SameRect(got, tt.want) {
+			t.Errorf("%s: UnionRect(%v, %v) = %v, want %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestScaleStraddlingWindow(t *testing.T) {
+	screens := grid(2, 1, 1920, 1080)
+	geo := xrect.New(1070, 100, 1000, 600)
+	// Scaled relative to the monitor it is on, so moving between identical monitors keeps its size
+	got := Scale(geo, SourceContainer(geo, screens), screens[1])
+	if got.Width() != 1000 || got.Height() != 600 {
+		t.Errorf("straddling window scaled to %v, want 1000x600", got)
+	}
+}
+
+func TestSourceContainerSpansOneAxis(t *testing.T) {
+	screens := lShapedLayout()
+	// Wider than a monitor and dipping onto the monitor below, only the monitors beside each other count
+	geo := xrect.New(500, 200, 2500, 1000)
+	if got, want := SourceContainer(geo, screens), UnionRect(screens[0], screens[1]); !SameRect(got, want) {
+		t.Errorf("SourceContainer(%v) = %v, want %v", geo, got, want)
+	}
+}
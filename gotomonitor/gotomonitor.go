@@ -0,0 +1,55 @@
+// Package gotomonitor moves X11 windows between monitors.
+//
+// Windows are located on a monitor by their largest overlap, the next
+// monitor is found by searching in a direction and the window is scaled so
+// it takes up the same fraction of the new monitor as it did of the old one.
+package gotomonitor
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xinerama"
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Debug logger, discards output by default
+var Debug = log.New(io.Discard, "", 0)
+
+// Moves the active window to the next monitor in the given direction
+func MoveActiveWindow(X *xgbutil.XUtil, dir Ordinal, wrap Wrap) error {
+	active_window_id, err := ewmh.ActiveWindowGet(X)
+	if err != nil {
+		return fmt.Errorf("error getting active window: %w", err)
+	}
+
+	active_window := xwindow.New(X, active_window_id)
+	current_geometry, err := active_window.DecorGeometry()
+	if err != nil {
+		return fmt.Errorf("error getting active window geometry: %w", err)
+	}
+
+	screens, err := xinerama.PhysicalHeads(X)
+	if err != nil {
+		return fmt.Errorf("error getting list of monitors: %w", err)
+	}
+
+	// Find monitor with largest overlap
+	index := xrect.LargestOverlap(current_geometry, screens)
+	if index == -1 {
+		return fmt.Errorf("active window does not overlap any monitor")
+	}
+	next_index := FindNextNear(index, screens, dir, wrap, current_geometry)
+
+	next_geometry, moved := PlanMove(current_geometry, screens, index, next_index)
+	if !moved {
+		// Nothing to do
+		return nil
+	}
+
+	return MoveWindow(active_window, next_geometry)
+}
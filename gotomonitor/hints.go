@@ -0,0 +1,67 @@
+package gotomonitor
+
+import (
+	"github.com/BurntSushi/xgbutil/icccm"
+)
+
+// Clamps a client size to the WM_NORMAL_HINTS aspect ratio, minimum and maximum size and rounds it down to the size increment,
+// so windows such as terminals end up the size that was asked for rather than whatever the application settles on
+func ApplySizeHints(w, h int, hints *icccm.NormalHints) (int, int) {
+	if hints == nil {
+		return w, h
+	}
+
+	w, h = applyAspect(w, h, hints)
+
+	if hints.Flags&icccm.SizeHintPResizeInc != 0 {
+		// Increments are counted from the base size, which defaults to the minimum size
+		basew, baseh := 0, 0
+		if hints.Flags&icccm.SizeHintPBaseSize != 0 {
+			basew, baseh = int(hints.BaseWidth), int(hints.BaseHeight)
+		} else if hints.Flags&icccm.SizeHintPMinSize != 0 {
+			basew, baseh = int(hints.MinWidth), int(hints.MinHeight)
+		}
+		if inc := int(hints.WidthInc); inc > 1 && w > basew {
+			w = basew + (w-basew)/inc*inc
+		}
+		if inc := int(hints.HeightInc); inc > 1 && h > baseh {
+			h = baseh + (h-baseh)/inc*inc
+		}
+	}
+
+	if hints.Flags&icccm.SizeHintPMinSize != 0 {
+		w = max(w, int(hints.MinWidth))
+		h = max(h, int(hints.MinHeight))
+	}
+	if hints.Flags&icccm.SizeHintPMaxSize != 0 {
+		if hints.MaxWidth > 0 {
+			w = min(w, int(hints.MaxWidth))
+		}
+		if hints.MaxHeight > 0 {
+			h = min(h, int(hints.MaxHeight))
+		}
+	}
+
+	return w, h
+}
+
+// Shrinks one side of a w by h window so its aspect ratio is within the WM_NORMAL_HINTS minimum and maximum aspect.
+// Only ever shrinking means the window still fits wherever the unadjusted size did
+func applyAspect(w, h int, hints *icccm.NormalHints) (int, int) {
+	if hints.Flags&icccm.SizeHintPAspect == 0 || w <= 0 || h <= 0 {
+		return w, h
+	}
+
+	minNum, minDen := int(hints.MinAspectNum), int(hints.MinAspectDen)
+	maxNum, maxDen := int(hints.MaxAspectNum), int(hints.MaxAspectDen)
+
+	// w/h < minNum/minDen, too tall
+	if minNum > 0 && minDen > 0 && w*minDen < h*minNum {
+		h = max(w*minDen/minNum, 1)
+	}
+	// w/h > maxNum/maxDen, too wide
+	if maxNum > 0 && maxDen > 0 && w*maxDen > h*maxNum {
+		w = max(h*maxNum/maxDen, 1)
+	}
+	return w, h
+}
@@ -0,0 +1,66 @@
+package gotomonitor
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/xgbutil/icccm"
+)
+
+func TestApplySizeHints(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h         int
+		hints        *icccm.NormalHints
+		wantW, wantH int
+	}{
+		{"no hints", 800, 600, nil, 800, 600},
+		{"clamped to the minimum", 200, 100, &icccm.NormalHints{
+			Flags: icccm.SizeHintPMinSize, MinWidth: 400, MinHeight: 300}, 400, 300},
+		{"clamped to the maximum", 2000, 1500, &icccm.NormalHints{
+			Flags: icccm.SizeHintPMaxSize, MaxWidth: 1280, MaxHeight: 1024}, 1280, 1024},
+		{"a zero maximum is no maximum", 2000, 1500, &icccm.NormalHints{
+			Flags: icccm.SizeHintPMaxSize}, 2000, 1500},
+		// A terminal with 9x18 cells and a 4x4 border
+		{"snapped to increments", 808, 610, &icccm.NormalHints{
+			Flags: icccm.SizeHintPResizeInc | icccm.SizeHintPBaseSize, BaseWidth: 4, BaseHeight: 4, WidthInc: 9, HeightInc: 18}, 805, 598},
+		{"increments from the minimum size", 805, 610, &icccm.NormalHints{
+			Flags: icccm.SizeHintPResizeInc | icccm.SizeHintPMinSize, MinWidth: 10, MinHeight: 10, WidthInc: 9, HeightInc: 18}, 802, 604},
+	}
+	for _, tt := range tests {
+		w, h := ApplySizeHints(tt.w, tt.h, tt.hints)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%s: ApplySizeHints(%d, %d) = %dx%d, want %dx%d", tt.name, tt.w, tt.h, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestApplySizeHintsAspect(t *testing.T) {
+	// A video player fixed at 16:9
+	video := &icccm.NormalHints{
+		Flags:        icccm.SizeHintPAspect,
+		MinAspectNum: 16, MinAspectDen: 9,
+		MaxAspectNum: 16, MaxAspectDen: 9,
+	}
+	tests := []struct {
+		name         string
+		w, h         int
+		wantW, wantH int
+	}{
+		// Scaled to fill a 16:10 monitor, too tall for 16:9
+		{"filling a 16:10 monitor", 1920, 1200, 1920, 1080},
+		{"half of a 16:10 monitor", 960, 600, 960, 540},
+		// Too wide, as when scaled to an ultrawide monitor
+		{"filling a 21:9 monitor", 3440, 1440, 2560, 1440},
+		{"already 16:9", 1280, 720, 1280, 720},
+	}
+	for _, tt := range tests {
+		w, h := ApplySizeHints(tt.w, tt.h, video)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%s: ApplySizeHints(%d, %d) = %dx%d, want %dx%d", tt.name, tt.w, tt.h, w, h, tt.wantW, tt.wantH)
+		}
+		// Only ever shrinks so it still fits where the unadjusted size did
+		if w > tt.w || h > tt.h {
+			t.Errorf("%s: ApplySizeHints(%d, %d) = %dx%d, grew the window", tt.name, tt.w, tt.h, w, h)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package gotomonitor
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Whether win is a real, visible client window that can be moved.
+// There is no window to move when nothing is focused (id 0 or the root window), and hidden (minimized) windows are left alone
+func IsMovable(X *xgbutil.XUtil, win *xwindow.Window) bool {
+	getState := func() []string {
+		// A window without _NET_WM_STATE has no states set
+		state, _ := ewmh.WmStateGet(X, win.Id)
+		return state
+	}
+	return movable(win.Id, X.RootWin(), getState)
+}
+
+// The checks of IsMovable, the window's _NET_WM_STATE is only read by getState if there is a window
+func movable(id, root xproto.Window, getState func() []string) bool {
+	if noWindow(id, root) {
+		Debug.Printf("No window to move")
+		return false
+	}
+
+	for _, x := range getState() {
+		if x == "_NET_WM_STATE_HIDDEN" {
+			Debug.Printf("Window %d is hidden", id)
+			return false
+		}
+	}
+
+	return true
+}
+
+// Whether id is no window at all, _NET_ACTIVE_WINDOW is 0 (or the root window) when nothing is focused
+func IsNoWindow(X *xgbutil.XUtil, id xproto.Window) bool {
+	return noWindow(id, X.RootWin())
+}
+
+func noWindow(id, root xproto.Window) bool {
+	return id == 0 || id == root
+}
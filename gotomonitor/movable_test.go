@@ -0,0 +1,37 @@
+package gotomonitor
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+func TestMovable(t *testing.T) {
+	const root xproto.Window = 0x1e6
+	tests := []struct {
+		name  string
+		id    xproto.Window
+		state []string
+		want  bool
+	}{
+		{"no active window", 0, nil, false},
+		{"root window", root, nil, false},
+		{"hidden window", 0x1c00007, []string{"_NET_WM_STATE_HIDDEN"}, false},
+		{"minimized maximized window", 0x1c00007, []string{"_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_HIDDEN"}, false},
+		{"normal window", 0x1c00007, nil, true},
+		{"maximized window", 0x1c00007, []string{"_NET_WM_STATE_MAXIMIZED_HORZ"}, true},
+	}
+	for _, tt := range tests {
+		read := false
+		getState := func() []string {
+			read = true
+			return tt.state
+		}
+		if got := movable(tt.id, root, getState); got != tt.want {
+			t.Errorf("%s: movable(%#x) = %v, want %v", tt.name, tt.id, got, tt.want)
+		}
+		if noWindow(tt.id, root) && read {
+			t.Errorf("%s: read the state of window %#x, which doesn't exist", tt.name, tt.id)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package gotomonitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// How long to wait for the window manager to drop blocking states before moving a window anyway, 0 doesn't wait
+var StateTimeout = 250 * time.Millisecond
+
+// How often _NET_WM_STATE is checked while waiting
+const statePollInterval = 10 * time.Millisecond
+
+// Waits until the window manager no longer reports any of atoms in win's _NET_WM_STATE, slow window managers
+// may otherwise still be applying the state when the window is moved and snap it back
+func waitForStateCleared(X *xgbutil.XUtil, win *xwindow.Window, atoms []string, timeout time.Duration) error {
+	return pollStateCleared(func() ([]string, error) {
+		return ewmh.WmStateGet(X, win.Id)
+	}, atoms, timeout, statePollInterval)
+}
+
+// Polls getState every interval until it reports none of atoms, or timeout passes
+func pollStateCleared(getState func() ([]string, error), atoms []string, timeout, interval time.Duration) error {
+	if len(atoms) == 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		state, err := getState()
+		if err != nil {
+			return err
+		}
+		remaining := intersectStates(state, atoms)
+		if len(remaining) == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("window still has %v after %v", remaining, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// States in both lists
+func intersectStates(state []string, atoms []string) []string {
+	var both []string
+	for _, x := range state {
+		for _, atom := range atoms {
+			if x == atom {
+				both = append(both, x)
+				break
+			}
+		}
+	}
+	return both
+}
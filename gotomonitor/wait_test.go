@@ -0,0 +1,49 @@
+package gotomonitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// A _NET_WM_STATE that still reports fullscreen for the first polls calls
+func clearsAfter(polls int) (func() ([]string, error), *int) {
+	calls := 0
+	return func() ([]string, error) {
+		calls++
+		if calls <= polls {
+			return []string{"_NET_WM_STATE_FULLSCREEN", "_NET_WM_STATE_ABOVE"}, nil
+		}
+		return []string{"_NET_WM_STATE_ABOVE"}, nil
+	}, &calls
+}
+
+func TestPollStateCleared(t *testing.T) {
+	atoms := []string{"_NET_WM_STATE_FULLSCREEN"}
+
+	getState, calls := clearsAfter(3)
+	if err := pollStateCleared(getState, atoms, time.Second, time.Millisecond); err != nil {
+		t.Errorf("pollStateCleared with a state clearing after 3 polls = %v", err)
+	}
+	if *calls != 4 {
+		t.Errorf("pollStateCleared polled %d times, want 4", *calls)
+	}
+
+	getState, calls = clearsAfter(1000)
+	if err := pollStateCleared(getState, atoms, 5*time.Millisecond, time.Millisecond); err == nil {
+		t.Errorf("pollStateCleared with a state that never clears didn't time out")
+	}
+	if *calls >= 1000 {
+		t.Errorf("pollStateCleared kept polling after the timeout")
+	}
+
+	getState, calls = clearsAfter(1000)
+	if err := pollStateCleared(getState, nil, time.Second, time.Millisecond); err != nil || *calls != 0 {
+		t.Errorf("pollStateCleared with no atoms = %v after %d polls, want nil without polling", err, *calls)
+	}
+
+	failing := func() ([]string, error) { return nil, errors.New("BadWindow") }
+	if err := pollStateCleared(failing, atoms, time.Second, time.Millisecond); err == nil {
+		t.Errorf("pollStateCleared didn't return the error reading the state")
+	}
+}
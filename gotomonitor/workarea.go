@@ -0,0 +1,48 @@
+package gotomonitor
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Usable region of a screen, with the space reserved by the _NET_WM_STRUT_PARTIAL of panels and docks removed
+func WorkArea(X *xgbutil.XUtil, screen xrect.Rect) (xrect.Rect, error) {
+	root, err := xwindow.RawGeometry(X, xproto.Drawable(X.RootWin()))
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := ewmh.ClientListGet(X)
+	if err != nil {
+		return nil, err
+	}
+
+	struts := make([]*ewmh.WmStrutPartial, 0, len(clients))
+	for _, client := range clients {
+		strut, err := ewmh.WmStrutPartialGet(X, client)
+		if err != nil {
+			// Most windows don't reserve any space
+			continue
+		}
+		struts = append(struts, strut)
+	}
+
+	return applyStruts(screen, uint(root.Width()), uint(root.Height()), struts), nil
+}
+
+func applyStruts(screen xrect.Rect, rootWidth, rootHeight uint, struts []*ewmh.WmStrutPartial) xrect.Rect {
+	// ApplyStrut modifies the rects it is given, work on a copy
+	area := []xrect.Rect{xrect.New(xrect.Pieces(screen))}
+	for _, strut := range struts {
+		xrect.ApplyStrut(area, rootWidth, rootHeight,
+			strut.Left, strut.Right, strut.Top, strut.Bottom,
+			strut.LeftStartY, strut.LeftEndY,
+			strut.RightStartY, strut.RightEndY,
+			strut.TopStartX, strut.TopEndX,
+			strut.BottomStartX, strut.BottomEndX)
+	}
+	return area[0]
+}
@@ -0,0 +1,38 @@
+package gotomonitor
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+func TestApplyStruts(t *testing.T) {
+	left := xrect.New(0, 0, 1920, 1080)
+	right := xrect.New(1920, 0, 1920, 1080)
+	// A 30 pixel panel along the top of the left monitor
+	top_panel := &ewmh.WmStrutPartial{Top: 30, TopStartX: 0, TopEndX: 1919}
+
+	tests := []struct {
+		name   string
+		screen xrect.Rect
+		struts []*ewmh.WmStrutPartial
+		want   xrect.Rect
+	}{
+		{"top strut", left, []*ewmh.WmStrutPartial{top_panel}, xrect.New(0, 30, 1920, 1050)},
+		{"strut on another monitor", right, []*ewmh.WmStrutPartial{top_panel}, right},
+		{"no struts", left, nil, left},
+	}
+	for _, tt := range tests {
+		got := applyStruts(tt.screen, 3840, 1080, tt.struts)
+		if !SameRect(got, tt.want) {
+			t.Errorf("%s: applyStruts(%v) = %v, want %v", tt.name, tt.screen, got, tt.want)
+		}
+	}
+
+	// The screen itself is left alone
+	applyStruts(left, 3840, 1080, []*ewmh.WmStrutPartial{top_panel})
+	if !SameRect(left, xrect.New(0, 0, 1920, 1080)) {
+		t.Errorf("applyStruts modified the screen it was given, now %v", left)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Prefixes every write, which log.Logger makes one line, with an RFC 3339 timestamp
+type timestampWriter struct {
+	w io.Writer
+}
+
+func (t timestampWriter) Write(p []byte) (int, error) {
+	_, err := fmt.Fprintf(t.w, "%s %s", time.Now().Format(time.RFC3339), p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Sends log output to the file at path, appending to it, or leaves it on stderr if path is empty.
+// Returns the debug logger and a function closing the log file
+func setupLogging(path string, verbose bool) (*log.Logger, func() error, error) {
+	if path == "" {
+		return newLogger(verbose), func() error { return nil }, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open log file: %v", err)
+	}
+	out := timestampWriter{file}
+	log.SetOutput(out)
+	log.SetFlags(0)
+
+	logger := log.New(io.Discard, "", 0)
+	if verbose {
+		logger = log.New(out, "debug: ", 0)
+	}
+	return logger, file.Close, nil
+}
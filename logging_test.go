@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestSetupLogging(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	defer log.SetFlags(log.LstdFlags)
+
+	path := filepath.Join(t.TempDir(), "go-to-monitor.log")
+	debug, closeLog, err := setupLogging(path, true)
+	if err != nil {
+		t.Fatalf("setupLogging(%q): %v", path, err)
+	}
+	log.Printf("Warning: window went away")
+	debug.Printf("Moving window 1")
+	if err := closeLog(); err != nil {
+		t.Fatalf("closing log file: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+	ts := `\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})`
+	want := regexp.MustCompile(`^` + ts + ` Warning: window went away\n` + ts + ` debug: Moving window 1\n$`)
+	if !want.Match(data) {
+		t.Errorf("log file contains %q, want timestamped lines", data)
+	}
+}
+
+func TestSetupLoggingMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "go-to-monitor.log")
+	if _, _, err := setupLogging(path, false); err == nil {
+		t.Errorf("setupLogging(%q) didn't fail", path)
+	}
+}
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
-	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/xgb/xproto"
 	"github.com/BurntSushi/xgbutil"
@@ -11,301 +19,1188 @@ import (
 	"github.com/BurntSushi/xgbutil/xinerama"
 	"github.com/BurntSushi/xgbutil/xrect"
 	"github.com/BurntSushi/xgbutil/xwindow"
+
+	"danielcranford/go-to-monitor/gotomonitor"
 )
 
-type Oridinal int
+// Debug logger, discards output unless verbose logging is enabled
+var debug = newLogger(false)
 
-const (
-	North Oridinal = iota
-	South
-	East
-	West
-)
+func newLogger(verbose bool) *log.Logger {
+	if !verbose {
+		return log.New(io.Discard, "", 0)
+	}
+	return log.New(os.Stderr, "debug: ", log.LstdFlags)
+}
 
-type RelativeGeometry struct {
-	x, y, width, height float64
+func parseDir(dirStr string) (gotomonitor.Ordinal, error) {
+	switch strings.ToLower(dirStr) {
+	case "e", "east", "right":
+		return gotomonitor.East, nil
+	case "w", "west", "left":
+		return gotomonitor.West, nil
+	case "n", "north", "up":
+		return gotomonitor.North, nil
+	case "s", "south", "down":
+		return gotomonitor.South, nil
+	case "ne", "northeast", "north-east":
+		return gotomonitor.NorthEast, nil
+	case "nw", "northwest", "north-west":
+		return gotomonitor.NorthWest, nil
+	case "se", "southeast", "south-east":
+		return gotomonitor.SouthEast, nil
+	case "sw", "southwest", "south-west":
+		return gotomonitor.SouthWest, nil
+	default:
+		// Not a valid direction, so a misspelling can't end up moving East
+		return -1, fmt.Errorf("unknown direction %q, expected North/South/East/West, left/right/up/down or a diagonal such as NorthEast", dirStr)
+	}
 }
 
-// Scales window geometry in integer units to fraction of screen in floating point units
-// This is a hack that sort of deals with monitors that are different sizes.
-// Moving a window that takes up a 1/4th of the screen to a monitor will resize the window to take 1/4 of the new monitor regardless of the actual monitor resolution and dimensions
-func build_relative(geo xrect.Rect, container xrect.Rect) RelativeGeometry {
-	return RelativeGeometry{
-		x:      float64(geo.X()-container.X()) / float64(container.Width()),
-		y:      float64(geo.Y()-container.Y()) / float64(container.Height()),
-		width:  float64(geo.Width()) / float64(container.Width()),
-		height: float64(geo.Height()) / float64(container.Height()),
+// Whether a window of the given _NET_WM_WINDOW_TYPE may be moved, panels, the desktop and splash screens are
+// positioned by the window manager and moving them makes a mess
+func isMovableType(types []string) bool {
+	for _, x := range types {
+		switch x {
+		case "_NET_WM_WINDOW_TYPE_DOCK", "_NET_WM_WINDOW_TYPE_DESKTOP", "_NET_WM_WINDOW_TYPE_SPLASH":
+			return false
+		}
 	}
+	return true
 }
 
-func build_absolute(rgeo RelativeGeometry, container xrect.Rect) xrect.Rect {
-	return xrect.New(
-		container.X()+int(rgeo.x*float64(container.Width())),
-		container.Y()+int(rgeo.y*float64(container.Height())),
-		int(rgeo.width*float64(container.Width())),
-		int(rgeo.height*float64(container.Height())),
-	)
+// Whether win has override-redirect set, menus and tooltips are not managed by the window manager and can't be moved through it
+func isOverrideRedirect(X *xgbutil.XUtil, win xproto.Window) (bool, error) {
+	return overrideRedirect(xproto.GetWindowAttributes(X.Conn(), win).Reply())
 }
 
-func overlaps_y(r xrect.Rect, r2 xrect.Rect) bool {
-	return r2.Y() < r.Y()+r.Height() && r2.Y()+r2.Height() > r.Y()
+// Reads override-redirect from a GetWindowAttributes reply
+func overrideRedirect(attributes *xproto.GetWindowAttributesReply, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	return attributes.OverrideRedirect, nil
 }
 
-func overlaps_x(r xrect.Rect, r2 xrect.Rect) bool {
-	return r2.X() < r.X()+r.Width() && r2.X()+r2.Width() > r.X()
+// Whether a window with this _NET_WM_STATE is sticky, shown on every desktop
+func isSticky(state []string) bool {
+	for _, x := range state {
+		if x == "_NET_WM_STATE_STICKY" {
+			return true
+		}
+	}
+	return false
 }
 
-// Scan list of screens to find the "next" screen in the given direction
-func find_next(curr xrect.Rect, screens []xrect.Rect, dir Oridinal, wrap bool) xrect.Rect {
-	// east/west, search x axis
-	pos := xrect.Rect.X
-	// only consider screens that have overlaping y dimensions
-	overlaps := overlaps_y
+// Checks shared by every way of picking a window to move. Returns why win should be left alone, or "" along with
+// opts adjusted for the window: a sticky window moved with -move-sticky is kept sticky
+func prepareMove(X *xgbutil.XUtil, opts options, win *xwindow.Window) (options, string, error) {
+	// There are no attributes to read without a window
+	if !gotomonitor.IsMovable(X, win) {
+		return opts, "it is not a visible window", nil
+	}
+
+	override_redirect, err := isOverrideRedirect(X, win.Id)
+	if err != nil {
+		return opts, "", fmt.Errorf("error getting window attributes: %w", err)
+	}
 
-	if dir == North || dir == South {
-		// north/south, search y-axis
-		pos = xrect.Rect.Y
-		// only consider screens that have overlaping x dimensions
-		overlaps = overlaps_x
+	// Most windows have no type and are normal windows, and a window without _NET_WM_STATE has no states set
+	types, _ := ewmh.WmWindowTypeGet(X, win.Id)
+	state, _ := ewmh.WmStateGet(X, win.Id)
+	skip := skipReason(override_redirect, types, state, opts.moveSticky)
+	if skip == "" && isSticky(state) {
+		// Other windows may be moved with the same options
+		extra := opts.extraStates[:len(opts.extraStates):len(opts.extraStates)]
+		opts.extraStates = append(extra, "_NET_WM_STATE_STICKY")
 	}
+	return opts, skip, nil
+}
 
-	i := 1
-	var not_found xrect.Rect = xrect.New(math.MaxInt, math.MaxInt, 0, 0)
-	// invert search direction for west or north
-	if dir == West || dir == North {
-		i = -1
-		not_found = xrect.New(math.MinInt, math.MinInt, 0, 0)
+// Why a window with these attributes, _NET_WM_WINDOW_TYPE and _NET_WM_STATE shouldn't be moved, or "" if it can be
+func skipReason(overrideRedirect bool, types, state []string, moveSticky bool) string {
+	switch {
+	case overrideRedirect:
+		return "it is not managed by the window manager (override-redirect)"
+	case !isMovableType(types):
+		return fmt.Sprintf("it is a %s", strings.Join(types, " "))
+	case isSticky(state) && !moveSticky:
+		return "it is sticky, use -move-sticky to move it"
 	}
-	next := not_found
-	global_min := not_found
+	return ""
+}
 
-	for _, r := range screens {
-		// skip curr and non-overlapping
-		if r == curr || !overlaps(r, curr) {
-			continue
+// Direction set by $GO_TO_MONITOR_DIRECTION, ok is false if it is unset or empty
+func directionFromEnv() (string, bool) {
+	dir := os.Getenv("GO_TO_MONITOR_DIRECTION")
+	return dir, dir != ""
+}
+
+// Sets -direction from $GO_TO_MONITOR_DIRECTION unless it was given on the command line.
+// Afterwards it counts as set, so the config file doesn't override it either
+func applyDirectionEnv(flags *flag.FlagSet) error {
+	if dir, ok := directionFromEnv(); ok && !flagSet(flags, "direction") {
+		return flags.Set("direction", dir)
+	}
+	return nil
+}
+
+// Direction to move, a positional argument (go-to-monitor east) takes precedence over -direction
+func resolveDirection(flagVal string, args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		return flagVal, nil
+	case 1:
+		return args[0], nil
+	default:
+		return "", fmt.Errorf("unexpected arguments %q, expected at most a direction (flags go before it)", args[1:])
+	}
+}
+
+// Parses a comma separated sequence of directions, e.g. South,East to move down then right
+func parseDirSequence(s string) ([]gotomonitor.Ordinal, error) {
+	var dirs []gotomonitor.Ordinal
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty direction in %q", s)
 		}
+		dir, err := parseDir(part)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// Parses a window id in hex (0x prefixed) or decimal
+func parseWindowID(s string) (xproto.Window, error) {
+	id, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window id %q: %v", s, err)
+	}
+	return xproto.Window(id), nil
+}
+
+// Resolve the window to move, either the window id given on the command line (hex or decimal) or the active window
+func resolveTargetWindow(X *xgbutil.XUtil, flagValue string) (*xwindow.Window, error) {
+	active := func() (xproto.Window, error) { return ewmh.ActiveWindowGet(X) }
+	exists := func(id xproto.Window) error {
+		_, err := xwindow.RawGeometry(X, xproto.Drawable(id))
+		return err
+	}
+	id, err := targetWindowID(flagValue, active, exists)
+	if err != nil {
+		return nil, err
+	}
+	return xwindow.New(X, id), nil
+}
 
-		// find first past curr
-		if i*pos(r) > i*pos(curr) &&
-			i*pos(r) < i*pos(next) {
-			next = r
+// Id of the window given by -window, or the active window if it is empty.
+// exists returns an error if there is no such window
+func targetWindowID(flagValue string, active func() (xproto.Window, error), exists func(xproto.Window) error) (xproto.Window, error) {
+	if flagValue == "" {
+		id, err := active()
+		if err != nil {
+			return 0, fmt.Errorf("error getting active window: %v", err)
 		}
+		return id, nil
+	}
+
+	id, err := parseWindowID(flagValue)
+	if err != nil {
+		return 0, err
+	}
+
+	// Make sure the window actually exists
+	err = exists(id)
+	if err != nil {
+		return 0, fmt.Errorf("window %s does not exist: %v", flagValue, err)
+	}
+	return id, nil
+}
 
-		// find global miniumum (for wrapping support)
-		if wrap && i*pos(r) < i*pos(global_min) {
-			global_min = r
+// One line per screen: index, position, size and a marker on the screen holding the active window
+func formatScreens(screens []xrect.Rect, current int) string {
+	var b strings.Builder
+	for i, r := range screens {
+		fmt.Fprintf(&b, "%d  %d,%d %dx%d", i, r.X(), r.Y(), r.Width(), r.Height())
+		if i == current {
+			b.WriteString(" *current")
 		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
 
+type ScreenReport struct {
+	Index  int `json:"index"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Rect used to find the monitor a window is on, either its decorated frame or just its client area
+func selectSourceRect(win *xwindow.Window, basis string) (xrect.Rect, error) {
+	client := func() (xrect.Rect, error) {
+		geo, err := xwindow.RawGeometry(win.X, xproto.Drawable(win.Id))
+		if err != nil {
+			return nil, err
+		}
+		// RawGeometry is relative to the window's parent, which is the frame in reparenting window managers
+		origin, err := xproto.TranslateCoordinates(win.X.Conn(), win.Id, win.X.RootWin(), 0, 0).Reply()
+		if err != nil {
+			return nil, err
+		}
+		return xrect.New(int(origin.DstX), int(origin.DstY), geo.Width(), geo.Height()), nil
 	}
+	return sourceRect(basis, win.DecorGeometry, client)
+}
 
-	if wrap && next == not_found {
-		next = global_min
+// Picks the geometry for basis, only the chosen one is read
+func sourceRect(basis string, frame, client func() (xrect.Rect, error)) (xrect.Rect, error) {
+	switch basis {
+	case "frame":
+		return frame()
+	case "client":
+		return client()
+	default:
+		return nil, fmt.Errorf("unknown overlap basis %q, expected client/frame", basis)
 	}
+}
 
-	if next == not_found {
-		next = curr
+// Index of the monitor win is on and, if RandR knows it, the name of its output
+func currentMonitor(X *xgbutil.XUtil, win *xwindow.Window, screens []xrect.Rect, basis string) (index int, name string, err error) {
+	index, err = monitorOfWindow(X, win, screens, basis)
+	if err != nil {
+		return -1, "", err
 	}
+	return index, monitorName(X, screens[index]), nil
+}
+
+// Index of the monitor win is on, the one most of its frame or client area, as basis says, overlaps
+func monitorOfWindow(X *xgbutil.XUtil, win *xwindow.Window, screens []xrect.Rect, basis string) (int, error) {
+	return windowMonitor(func() (xrect.Rect, error) {
+		return selectSourceRect(win, basis)
+	}, screens)
+}
 
-	return next
+// Index of the monitor the window whose geometry returns is on
+func windowMonitor(geometry func() (xrect.Rect, error), screens []xrect.Rect) (int, error) {
+	geo, err := geometry()
+	if err != nil {
+		return -1, fmt.Errorf("error getting window geometry: %v", err)
+	}
+	return monitorOfGeometry(geo, screens)
+}
 
+// Index of the monitor a window with geometry geo is on
+func monitorOfGeometry(geo xrect.Rect, screens []xrect.Rect) (int, error) {
+	index := resolveSourceIndex(geo, screens)
+	if index == -1 {
+		return -1, fmt.Errorf("no monitors")
+	}
+	return index, nil
 }
 
-// This should be in xbgutil
-type EwmhClientSource int
+// Machine readable description of the monitor layout and the move that would be made
+type LayoutReport struct {
+	Screens      []ScreenReport `json:"screens"`
+	ActiveWindow uint32         `json:"active_window"`
+	Current      int            `json:"current"`
+	Next         int            `json:"next"`
+}
 
-const (
-	Unknown EwmhClientSource = iota
-	Application
-	Pager
-)
+func buildReport(screens []xrect.Rect, window xproto.Window, current, next int) LayoutReport {
+	report := LayoutReport{
+		Screens:      make([]ScreenReport, 0, len(screens)),
+		ActiveWindow: uint32(window),
+		Current:      current,
+		Next:         next,
+	}
+	for i, r := range screens {
+		report.Screens = append(report.Screens, ScreenReport{
+			Index:  i,
+			X:      r.X(),
+			Y:      r.Y(),
+			Width:  r.Width(),
+			Height: r.Height(),
+		})
+	}
+	return report
+}
 
-func WmStateReqExtra2(win xwindow.Window, action int, source EwmhClientSource,
-	atoms ...string) error {
+// Moves the mouse pointer to the given root window coordinates
+func warpPointer(X *xgbutil.XUtil, x, y int) error {
+	return xproto.WarpPointerChecked(X.Conn(), xproto.WindowNone, X.RootWin(),
+		0, 0, 0, 0, int16(x), int16(y)).Check()
+}
 
-	var i int
-	for i = 0; i < len(atoms)/2; i++ {
-		// ewmh _NET_WM_STATE client message accepts 2 atoms at a time
-		// unknown if a simple property update to the _NET_WM_STATE property is supported since ewmh specifies the _NET_WM_STATE value must be updated via the client message
-		first := atoms[i*2]
-		second := atoms[1*2+1]
-		err := ewmh.WmStateReqExtra(win.X, win.Id, action, first, second, int(source))
-		if err != nil {
-			return err
+// List monitors using the given backend, randr or xinerama
+// Mirrored monitors are listed once and zero size monitors not at all
+func heads(X *xgbutil.XUtil, backend string) ([]xrect.Rect, error) {
+	var screens []xrect.Rect
+	var err error
+	switch backend {
+	case "randr":
+		screens, err = randrHeads(X)
+	case "xinerama":
+		screens, err = xinerama.PhysicalHeads(X)
+	default:
+		return nil, fmt.Errorf("unknown backend %q, expected randr/xinerama", backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	valid := filterValidScreens(screens)
+	if len(valid) != len(screens) {
+		debug.Printf("Ignoring zero size monitors in %v", screens)
+	}
+	deduped, originals := dedupeMirrored(valid)
+	if len(deduped) != len(valid) {
+		debug.Printf("Treating mirrored monitors as one, using monitors %v of %v", originals, valid)
+	}
+	return deduped, nil
+}
+
+// Leaves out monitors with no area, which some drivers report and which can't be scaled to or from
+func filterValidScreens(screens []xrect.Rect) []xrect.Rect {
+	valid := make([]xrect.Rect, 0, len(screens))
+	for _, r := range screens {
+		if r.Width() > 0 && r.Height() > 0 {
+			valid = append(valid, r)
 		}
 	}
+	return valid
+}
 
-	// Finish the tail
-	if i*2 < len(atoms) {
-		err := ewmh.WmStateReqExtra(win.X, win.Id, action, atoms[i*2], "", int(source))
-		if err != nil {
-			return err
+// Fraction of the smaller of two monitors they must share to be treated as mirrors of each other
+const mirrorThreshold = 0.9
+
+// Collapses monitors showing (almost) the same region into one, since there is no moving between them.
+// originals[i] is the index in screens of the monitor kept as deduped[i]
+func dedupeMirrored(screens []xrect.Rect) (deduped []xrect.Rect, originals []int) {
+	for i, r := range screens {
+		mirrored := false
+		for _, kept := range deduped {
+			if mirrorFraction(r, kept) > mirrorThreshold {
+				mirrored = true
+				break
+			}
 		}
+		if !mirrored {
+			deduped = append(deduped, r)
+			originals = append(originals, i)
+		}
+	}
+	return deduped, originals
+}
+
+// Area shared by a and b as a fraction of the smaller of the two
+func mirrorFraction(a, b xrect.Rect) float64 {
+	smaller := a.Width() * a.Height()
+	if area := b.Width() * b.Height(); area < smaller {
+		smaller = area
+	}
+	if smaller <= 0 {
+		return 0
 	}
+	return float64(xrect.IntersectArea(a, b)) / float64(smaller)
+}
 
-	return nil
+// Whether there is anywhere to move a window to, there isn't with a single monitor
+func shouldMove(screens []xrect.Rect) bool {
+	return len(screens) > 1
 }
 
-// xwindow.adjustSize has a bug where parent window is not retrieved
-// adjustSize takes a client and dimensions, and adjust them so that they'll
-// account for window decorations. For example, if you want a window to be
-// 200 pixels wide, a window manager will typically determine that as
-// you wanting the *client* to be 200 pixels wide. The end result is that
-// the client plus decorations ends up being
-// (200 + left decor width + right decor width) pixels wide. Which is probably
-// not what you want. Therefore, transform 200 into
-// 200 - decoration window width - client window width.
-// Similarly for height.
-func adjustSize(win xwindow.Window,
-	w, h int) (int, int, error) {
+// Check that a Xinerama head index refers to an existing screen
+func validMonitor(screens []xrect.Rect, index int) error {
+	if index < 0 || index >= len(screens) {
+		return fmt.Errorf("monitor %d out of range, %d monitors available", index, len(screens))
+	}
+	return nil
+}
 
-	// raw client geometry
-	cGeom, err := xwindow.RawGeometry(win.X, xproto.Drawable(win.Id))
+// Index of the monitor given by -monitor, either an absolute index or +N/-N relative to current
+// Relative moves step through the monitors in order, a list of indices into screens
+func resolveMonitor(spec string, current int, screens []xrect.Rect, order []int, wrap bool) (int, error) {
+	if strings.HasPrefix(spec, "+") || strings.HasPrefix(spec, "-") {
+		position := 0
+		for i, screen := range order {
+			if screen == current {
+				position = i
+			}
+		}
+		next, err := resolveRelativeMonitor(position, spec, len(order), wrap)
+		if err != nil {
+			return -1, err
+		}
+		return order[next], nil
+	}
+	index, err := strconv.Atoi(spec)
 	if err != nil {
-		return 0, 0, err
+		return -1, fmt.Errorf("invalid monitor %q, expected an index or +N/-N", spec)
+	}
+	return index, validMonitor(screens, index)
+}
+
+// Indices of screens sorted for -monitor-order: left to right (x), top to bottom (y), or by one then the other
+// (xy, yx). The empty order keeps the order the monitors were listed in
+func screenOrder(screens []xrect.Rect, order string) ([]int, error) {
+	indices := make([]int, len(screens))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	var keys []func(xrect.Rect) int
+	switch order {
+	case "":
+	case "x":
+		keys = []func(xrect.Rect) int{xrect.Rect.X}
+	case "y":
+		keys = []func(xrect.Rect) int{xrect.Rect.Y}
+	case "xy":
+		keys = []func(xrect.Rect) int{xrect.Rect.X, xrect.Rect.Y}
+	case "yx":
+		keys = []func(xrect.Rect) int{xrect.Rect.Y, xrect.Rect.X}
+	default:
+		return nil, fmt.Errorf("unknown monitor order %q, expected x/y/xy/yx", order)
+	}
+
+	sort.SliceStable(indices, func(a, b int) bool {
+		ra, rb := screens[indices[a]], screens[indices[b]]
+		for _, key := range keys {
+			if key(ra) != key(rb) {
+				return key(ra) < key(rb)
+			}
+		}
+		return false
+	})
+	return indices, nil
+}
+
+// Index of the monitor offset by spec (e.g. +1, -2) from current in enumeration order.
+// Without wrapping the first and last monitors are as far as it goes
+func resolveRelativeMonitor(current int, spec string, n int, wrap bool) (int, error) {
+	offset, err := strconv.Atoi(spec)
+	if err != nil || (!strings.HasPrefix(spec, "+") && !strings.HasPrefix(spec, "-")) {
+		return -1, fmt.Errorf("invalid relative monitor %q, expected +N or -N", spec)
+	}
+	if n <= 0 {
+		return -1, fmt.Errorf("no monitors to move to")
 	}
 
-	// geometry with decorations
-	decorations, err := DecorWindow(&win)
+	next := current + offset
+	if wrap {
+		return ((next % n) + n) % n, nil
+	}
+	if next < 0 {
+		return 0, nil
+	}
+	if next >= n {
+		return n - 1, nil
+	}
+	return next, nil
+}
+
+// Find the screen containing the given point, screens include their top and left edges but not their bottom and right edges
+func screenContainingPoint(x, y int, screens []xrect.Rect) int {
+	for i, r := range screens {
+		if x >= r.X() && x < r.X()+r.Width() &&
+			y >= r.Y() && y < r.Y()+r.Height() {
+			return i
+		}
+	}
+	return -1
+}
+
+// Position of the mouse pointer on the root window
+func pointerPosition(X *xgbutil.XUtil) (x, y int, err error) {
+	pointer, err := xproto.QueryPointer(X.Conn(), X.RootWin()).Reply()
 	if err != nil {
 		return 0, 0, err
 	}
+	return int(pointer.RootX), int(pointer.RootY), nil
+}
+
+// The screen a window with geometry geo is on, the one it overlaps most or, if it is entirely off screen, the nearest.
+// Only -1 if there are no screens
+func resolveSourceIndex(geo xrect.Rect, screens []xrect.Rect) int {
+	return gotomonitor.NearestScreen(geo, screens)
+}
+
+// Index of the screen with the greatest area, the first of them if several are as large
+func largestScreen(screens []xrect.Rect) int {
+	return screenByArea(screens, func(area, best int) bool { return area > best })
+}
+
+// Index of the screen with the least area, the first of them if several are as small.
+// Zero size screens are left out when listing monitors so are never chosen
+func smallestScreen(screens []xrect.Rect) int {
+	return screenByArea(screens, func(area, best int) bool { return area < best })
+}
+
+// Index of the first screen whose area no other screen's area beats
+func screenByArea(screens []xrect.Rect, beats func(area, best int) bool) int {
+	best := -1
+	for i, r := range screens {
+		if best == -1 || beats(r.Width()*r.Height(), screens[best].Width()*screens[best].Height()) {
+			best = i
+		}
+	}
+	return best
+}
 
-	pGeom, err := xwindow.RawGeometry(win.X, xproto.Drawable(decorations.Id))
+// Find the screen the mouse pointer is on
+func pointerScreen(X *xgbutil.XUtil, screens []xrect.Rect) (int, error) {
+	x, y, err := pointerPosition(X)
 	if err != nil {
-		return 0, 0, err
+		return -1, err
 	}
 
-	neww := w - (pGeom.Width() - cGeom.Width())
-	newh := h - (pGeom.Height() - cGeom.Height())
-	if neww < 1 {
-		neww = 1
+	index := screenContainingPoint(x, y, screens)
+	if index == -1 {
+		// Pointer is in a gap between monitors, use whichever monitor is closest
+		const size = 64
+		index = xrect.LargestOverlap(xrect.New(x-size/2, y-size/2, size, size), screens)
 	}
-	if newh < 1 {
-		newh = 1
+	if index == -1 {
+		return -1, fmt.Errorf("pointer at %d,%d is not on any monitor", x, y)
+	}
+	return index, nil
+}
+
+func parseWrap(wrapStr string) (gotomonitor.Wrap, error) {
+	switch strings.ToLower(wrapStr) {
+	case "all", "true":
+		return gotomonitor.WrapAll, nil
+	case "none", "false":
+		return gotomonitor.WrapNone, nil
+	case "horizontal":
+		return gotomonitor.WrapHorizontal, nil
+	case "vertical":
+		return gotomonitor.WrapVertical, nil
+	default:
+		return gotomonitor.WrapNone, fmt.Errorf("unknown wrap %q, expected all/none/horizontal/vertical", wrapStr)
 	}
-	return neww, newh, nil
 }
 
-// xwindow.WMMoveResize has a bug where decorations are not accounted for
-//
-// WMMoveResize is an accurate means of resizing a window, accounting for
-// decorations. Usually, the x,y coordinates are fine---we just need to
-// adjust the width and height.
-// This should be used when moving/resizing top-level client windows with
-// reparenting window managers that support EWMH.
-func WMMoveResize(w xwindow.Window, x, y, width, height int) error {
-	neww, newh, err := adjustSize(w, width, height)
+// flag.Value for -wrap, a bool flag so a bare -wrap and -wrap=false keep working
+type wrapFlag struct {
+	value gotomonitor.Wrap
+	str   string
+}
+
+func (f *wrapFlag) String() string {
+	return f.str
+}
+
+func (f *wrapFlag) Set(s string) error {
+	wrap, err := parseWrap(s)
 	if err != nil {
 		return err
 	}
-	return ewmh.MoveresizeWindowExtra(w.X, w.Id, x, y, neww, newh,
-		xproto.GravityBitForget, 2, true, true)
+	f.value, f.str = wrap, s
+	return nil
 }
 
-// Logic lifted from xwindow.DecorGeometry
-func DecorWindow(w *xwindow.Window) (*xwindow.Window, error) {
-	parent := w
-	for {
-		tempParent, err := parent.Parent()
-		if err != nil || tempParent.Id == w.X.RootWin() {
-			return parent, err
+func (f *wrapFlag) IsBoolFlag() bool {
+	return true
+}
+
+// flag.Value for string flags that may also be given bare like a bool flag, in which case they are set to "true"
+type boolStringFlag string
+
+func (f *boolStringFlag) String() string {
+	return string(*f)
+}
+
+func (f *boolStringFlag) Set(s string) error {
+	*f = boolStringFlag(s)
+	return nil
+}
+
+func (f *boolStringFlag) IsBoolFlag() bool {
+	return true
+}
+
+// Flags that can be given bare like a bool flag, with the parser for their value
+var boolStringFlags = map[string]func(string) error{
+	"wrap": func(s string) error {
+		_, err := parseWrap(s)
+		return err
+	},
+	"maximize": func(s string) error {
+		_, err := statesToApplyAfterMove(s)
+		return err
+	},
+	"focus-after-move": func(s string) error {
+		_, err := parseFocusMode(s)
+		return err
+	},
+}
+
+// Catches a value given to a bare-able flag without =, "-wrap none" parses as a bare -wrap (wrap everything)
+// followed by the argument none, the opposite of what was asked. args are the command line arguments and rest
+// those left over after parsing flags
+func checkDetachedValue(args []string, rest []string) error {
+	if len(rest) == 0 || len(rest) >= len(args) || rest[0] == "" {
+		return nil
+	}
+	last := args[len(args)-len(rest)-1]
+	name := strings.TrimPrefix(strings.TrimPrefix(last, "-"), "-")
+	valid, ok := boolStringFlags[name]
+	if !ok || !strings.HasPrefix(last, "-") || valid(rest[0]) != nil {
+		return nil
+	}
+	return fmt.Errorf("%s %s reads as a bare %s followed by the argument %q, use -%s=%s", last, rest[0], last, rest[0], name, rest[0])
+}
+
+// States to add to a window once it has moved for the -maximize flag
+func statesToApplyAfterMove(flag string) ([]string, error) {
+	switch strings.ToLower(flag) {
+	case "", "false":
+		return nil, nil
+	case "horz":
+		return []string{"_NET_WM_STATE_MAXIMIZED_HORZ"}, nil
+	case "vert":
+		return []string{"_NET_WM_STATE_MAXIMIZED_VERT"}, nil
+	case "both", "true":
+		return []string{"_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_MAXIMIZED_VERT"}, nil
+	default:
+		return nil, fmt.Errorf("unknown maximize %q, expected horz/vert/both", flag)
+	}
+}
+
+// Parses -strip-states, a comma separated list of _NET_WM_STATE atoms. The _NET_WM_STATE_ prefix may be left off
+func parseStripStates(s string) []string {
+	var states []string
+	for _, x := range strings.Split(s, ",") {
+		x = strings.ToUpper(strings.TrimSpace(x))
+		if x == "" {
+			continue
+		}
+		if !strings.HasPrefix(x, "_NET_WM_STATE_") {
+			x = "_NET_WM_STATE_" + x
 		}
-		parent = tempParent
+		states = append(states, x)
 	}
+	return states
 }
 
-func parseDir(dirStr string) Oridinal {
-	switch dirStr[0] {
-	case 'E':
-		return East
-	case 'W':
-		return West
-	case 'N':
-		return North
-	case 'S':
-		return South
+// Maps a -gravity name to its xproto.Gravity* constant
+func parseGravity(name string) (int, error) {
+	switch strings.ToLower(name) {
+	case "forget":
+		return xproto.GravityBitForget, nil
+	case "northwest", "nw":
+		return xproto.GravityNorthWest, nil
+	case "north", "n":
+		return xproto.GravityNorth, nil
+	case "northeast", "ne":
+		return xproto.GravityNorthEast, nil
+	case "west", "w":
+		return xproto.GravityWest, nil
+	case "center":
+		return xproto.GravityCenter, nil
+	case "east", "e":
+		return xproto.GravityEast, nil
+	case "southwest", "sw":
+		return xproto.GravitySouthWest, nil
+	case "south", "s":
+		return xproto.GravitySouth, nil
+	case "southeast", "se":
+		return xproto.GravitySouthEast, nil
+	case "static":
+		return xproto.GravityStatic, nil
 	default:
-		return East
+		return 0, fmt.Errorf("unknown gravity %q, expected a direction such as NorthWest, center, static or forget", name)
+	}
+}
+
+// Parses -preserve-fraction, a fraction 1/N, returning N
+func parseFractionGrid(s string) (int, error) {
+	denom, err := strconv.Atoi(strings.TrimPrefix(s, "1/"))
+	if !strings.HasPrefix(s, "1/") || err != nil || denom <= 0 {
+		return 0, fmt.Errorf("invalid fraction %q, expected 1/N such as 1/2 or 1/3", s)
+	}
+	return denom, nil
+}
+
+// Checks -min-scale and -max-scale, 0 leaves out a limit
+func checkScaleLimits(minScale, maxScale float64) error {
+	if minScale < 0 || maxScale < 0 {
+		return fmt.Errorf("invalid scale limits, -min-scale %v and -max-scale %v can't be negative", minScale, maxScale)
+	}
+	if minScale > 0 && maxScale > 0 && minScale > maxScale {
+		return fmt.Errorf("invalid scale limits, -min-scale %v is more than -max-scale %v", minScale, maxScale)
+	}
+	return nil
+}
+
+// Parses -monitor-scale, comma separated output=factor pairs such as eDP-1=2,DP-2=1
+func parseMonitorScales(s string) (map[string]float64, error) {
+	scales := map[string]float64{}
+	if s == "" {
+		return scales, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid monitor scale %q, expected output=factor", pair)
+		}
+		scale, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || scale <= 0 {
+			return nil, fmt.Errorf("invalid scale factor for %s: %q", name, value)
+		}
+		scales[name] = scale
 	}
+	return scales, nil
 }
 
 func main() {
+	result, err := run()
+	if err != nil {
+		// Already logged by run
+		os.Exit(1)
+	}
+	os.Exit(result.exitCode())
+}
+
+// Does whatever the flags ask for. Errors are logged before returning them, and modes that don't move a window
+// (e.g. -list) report moved when they succeed so they exit 0
+func run() (result moveResult, err error) {
+	closeLog := func() error { return nil }
+	defer func() {
+		// Log before the log file is closed
+		if err != nil {
+			log.Print(err)
+		}
+		closeLog()
+	}()
+
+	var opts options
 	var dirStr string
-	var wrap bool
-	flag.StringVar(&dirStr, "direction", "East", "direction to move (North, South, East, West)")
-	flag.BoolVar(&wrap, "wrap", true, "enable wrapping")
+	wrap := wrapFlag{gotomonitor.WrapAll, "all"}
+	var maximize boolStringFlag
+	var keepAbove bool
+	var confirm bool
+	var confirmDuration time.Duration
+	var andDesktop string
+	var focusAfterMove boolStringFlag
+	var windowStr string
+	var backend string
+	var gravity string
+	var noDecorAdjust bool
+	var stripStates string
+	var monitorScales string
+	var preserveFraction string
+	var configPath string
+	var verbose bool
+	var logFile string
+	var stateTimeout time.Duration
+	var list bool
+	var query bool
+	var dumpEwmh bool
+	var jsonOutput bool
+	var undo bool
+	var all bool
+	var swap bool
+	var nearest bool
+	var spanNext bool
+	var fromStdin bool
+	var runAsDaemon bool
+	var client bool
+	var evacuateSrc int
+	var evacuateDst int
+	flag.StringVar(&dirStr, "direction", "East", "direction to move (North, South, East, West, NorthEast, NorthWest, SouthEast, SouthWest, or left, right, up, down), or several separated by commas to move in turn. Defaults to $GO_TO_MONITOR_DIRECTION if set")
+	flag.Var(&wrap, "wrap", "enable wrapping (all, none, horizontal, vertical), give the value with = as in -wrap=none")
+	flag.Float64Var(&opts.minOverlap, "min-overlap", 0, "fraction of its side a monitor must share with the current monitor to be moved to")
+	flag.StringVar(&opts.monitor, "monitor", "", "move to the monitor with this index, or +N/-N monitors on from the current one, instead of moving in a direction")
+	flag.BoolVar(&nearest, "nearest", false, "move the window fully onto the monitor it is on, or nearest to, instead of moving in a direction")
+	flag.BoolVar(&opts.toCursor, "to-cursor", false, "move to the monitor containing the mouse pointer instead of moving in a direction")
+	flag.StringVar(&opts.output, "output", "", "move to the monitor driven by this RandR output (e.g. DP-2) instead of moving in a direction")
+	flag.StringVar(&opts.outputRegex, "output-regex", "", "move to the first monitor whose RandR output name matches this regular expression (e.g. ^DP-)")
+	flag.StringVar(&opts.toWindow, "to-window", "", "move to the monitor showing the window with this id (hex or decimal) instead of moving in a direction")
+	flag.BoolVar(&opts.primary, "primary", false, "move to the primary monitor instead of moving in a direction")
+	flag.StringVar(&opts.monitorOrder, "monitor-order", "", "order -cycle and -monitor +N/-N step through monitors in (x, y, xy, yx), by default left to right for -cycle and as listed for -monitor")
+	flag.BoolVar(&opts.toLargest, "to-largest", false, "move to the monitor with the largest area instead of moving in a direction")
+	flag.BoolVar(&opts.toSmallest, "to-smallest", false, "move to the monitor with the smallest area instead of moving in a direction")
+	flag.BoolVar(&opts.cycle, "cycle", false, "move to the next monitor ordered left to right, top to bottom, instead of moving in a direction")
+	flag.StringVar(&windowStr, "window", "", "id of the window to move (hex or decimal), defaults to the active window")
+	flag.StringVar(&backend, "backend", "xinerama", "how to list monitors (randr, xinerama)")
+	flag.StringVar(&opts.overlapBasis, "overlap-basis", "frame", "whether the window's monitor is the one overlapping most of its decorated frame or its client area (frame, client)")
+	flag.BoolVar(&opts.respectStruts, "respect-struts", false, "keep the window out of space reserved by panels and docks on the target monitor")
+	flag.StringVar(&opts.snap, "snap", "", "snap the window to part of the target monitor (left, right, top, bottom, full, tl, tr, bl, br)")
+	flag.BoolVar(&opts.center, "center", false, "center the window on the target monitor, keeping its size")
+	flag.BoolVar(&opts.centerCursor, "center-cursor", false, "center the window on the mouse pointer, as far as it stays on the target monitor")
+	flag.BoolVar(&opts.keepSize, "keep-size", false, "keep the window's size in pixels instead of scaling it to the target monitor")
+	flag.IntVar(&opts.resizePercent, "resize-to-percent", 0, "resize the window to this percentage of the target monitor (or -snap region), centered")
+	flag.StringVar(&opts.scaleBy, "scale-by", "pixel", "keep the window the same fraction of the monitor in pixels, or the same physical size (pixel, physical)")
+	flag.StringVar(&monitorScales, "monitor-scale", "", "scale factors of HiDPI monitors by RandR output (e.g. eDP-1=2), windows keep their apparent size moving between differently scaled monitors")
+	flag.StringVar(&preserveFraction, "preserve-fraction", "", "round the window's position and size on the target monitor to multiples of this fraction of it (e.g. 1/2, 1/3, 1/4)")
+	flag.Float64Var(&opts.maxScale, "max-scale", 0, "most a window may grow by moving to a bigger monitor, e.g. 1.5 for half as big again")
+	flag.Float64Var(&opts.minScale, "min-scale", 0, "least a window may shrink to moving to a smaller monitor, e.g. 0.5 for half its size")
+	flag.BoolVar(&opts.integerScale, "integer-scale", false, "scale the window by a whole multiple or divisor of the monitor size ratio")
+	flag.BoolVar(&noDecorAdjust, "no-decor-adjust", false, "don't allow for window decorations when resizing, for window managers without them")
+	flag.StringVar(&gravity, "gravity", "NorthWest", "gravity of move requests to the window manager (NorthWest, North, ..., center, static, forget)")
+	flag.IntVar(&opts.edgeMargin, "edge-margin", 0, "place the window this many pixels in from the edge of the target monitor it moved in through")
+	flag.IntVar(&opts.gap, "gap", 0, "pixels of space to leave around the moved window")
+	flag.Var(&maximize, "maximize", "maximize the window after moving it (horz, vert, both), give the value with = as in -maximize=vert")
+	flag.StringVar(&stripStates, "strip-states", strings.Join(gotomonitor.BlockingStates, ","), "comma separated _NET_WM_STATE atoms to remove while moving a window and restore afterwards")
+	flag.BoolVar(&opts.noRestore, "no-restore-state", false, "leave a maximized or fullscreen window unmaximized after moving it")
+	flag.BoolVar(&opts.moveSticky, "move-sticky", false, "move sticky windows (shown on every desktop) too, keeping them sticky")
+	flag.BoolVar(&keepAbove, "keep-above", false, "keep the window above others after moving it, some window managers drop this when a window moves")
+	flag.BoolVar(&confirm, "confirm", false, "briefly highlight the target monitor before moving the window")
+	flag.DurationVar(&confirmDuration, "confirm-duration", 300*time.Millisecond, "how long -confirm highlights the target monitor for")
+	flag.BoolVar(&opts.raise, "raise", false, "raise and focus the window after moving it, only raise it with -focus-after-move=false")
+	flag.Var(&focusAfterMove, "focus-after-move", "whether to focus the window after moving it (-focus-after-move=true, -focus-after-move=false), by default it keeps focus if it had it")
+	flag.BoolVar(&opts.warp, "warp-pointer", false, "move the mouse pointer to the center of the window after moving it")
+	flag.StringVar(&andDesktop, "and-desktop", "", "also send the window this many desktops on (e.g. +1, -1), wrapping if -wrap allows")
+	flag.BoolVar(&opts.followDesktop, "follow-desktop", false, "switch to the window's desktop after moving it if it is no longer on the current one")
+	flag.BoolVar(&opts.notify, "notify", false, "show a desktop notification after moving the window")
+	flag.DurationVar(&stateTimeout, "state-timeout", gotomonitor.StateTimeout, "how long to wait for the window manager to unmaximize a window before moving it")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "log the planned move without moving the window")
+	flag.BoolVar(&list, "list", false, "list monitors and exit")
+	flag.BoolVar(&query, "query", false, "print the index and output name of the monitor the window is on and exit")
+	flag.BoolVar(&dumpEwmh, "dump-ewmh", false, "print the window's EWMH state, geometry and monitor and exit")
+	flag.BoolVar(&jsonOutput, "json", false, "print the monitor layout and planned move as JSON and exit without moving")
+	flag.BoolVar(&undo, "undo", false, "move the window back to where it was before its last move, repeat to step further back")
+	flag.BoolVar(&fromStdin, "stdin", false, "move the windows whose ids are read from standard input, one per line")
+	flag.BoolVar(&spanNext, "span-next", false, "stretch the window across its monitor and the next one in the direction instead of moving it")
+	flag.BoolVar(&swap, "swap", false, "also move the top window on the target monitor back to the active window's monitor")
+	flag.BoolVar(&all, "all", false, "move every window on the active window's monitor, not just the active window")
+	flag.BoolVar(&opts.allDesktops, "include-all-desktops", false, "have -all and -evacuate move windows on every desktop, not just the current one")
+	flag.IntVar(&evacuateSrc, "evacuate", -1, "move every window off the monitor with this index, to the monitor given by -to")
+	flag.IntVar(&evacuateDst, "to", -1, "monitor index -evacuate moves windows to")
+	flag.BoolVar(&runAsDaemon, "daemon", false, "keep running, taking commands from a unix socket in $XDG_RUNTIME_DIR (or the user cache directory)")
+	flag.BoolVar(&client, "socket", false, "send the command given as arguments (e.g. move East wrap=none, list) to a running daemon")
+	flag.StringVar(&configPath, "config", "", "config file setting defaults for these flags (default $XDG_CONFIG_HOME/go-to-monitor/config.toml)")
+	flag.BoolVar(&verbose, "v", false, "enable verbose debug logging")
+	flag.StringVar(&logFile, "log-file", "", "append log messages to this file instead of writing them to stderr")
 	flag.Parse()
+	err = checkDetachedValue(os.Args[1:], flag.Args())
+	if err != nil {
+		return notMoved, err
+	}
+
+	// The environment overrides the config file but not the command line
+	err = applyDirectionEnv(flag.CommandLine)
+	if err != nil {
+		return notMoved, err
+	}
+
+	if configPath == "" {
+		configPath = defaultConfigPath()
+	} else if _, err := os.Stat(configPath); err != nil {
+		// Only the default config file is optional
+		return notMoved, fmt.Errorf("Error reading config file: %v", err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return notMoved, fmt.Errorf("Error reading config file: %v", err)
+	}
+	err = applyConfig(flag.CommandLine, config)
+	if err != nil {
+		return notMoved, err
+	}
+
+	var closeLogFile func() error
+	debug, closeLogFile, err = setupLogging(logFile, verbose)
+	if err != nil {
+		return notMoved, err
+	}
+	closeLog = closeLogFile
+	gotomonitor.Debug = debug
+	gotomonitor.StateTimeout = stateTimeout
+	gotomonitor.AdjustDecorations = !noDecorAdjust
+	gotomonitor.BlockingStates = parseStripStates(stripStates)
+	gotomonitor.Gravity, err = parseGravity(gravity)
+	if err != nil {
+		return notMoved, err
+	}
+
+	if !client {
+		// With -socket the arguments are the command for the daemon
+		dirStr, err = resolveDirection(dirStr, flag.Args())
+		if err != nil {
+			return notMoved, err
+		}
+	}
+	opts.dirs, err = parseDirSequence(dirStr)
+	if err != nil {
+		return notMoved, err
+	}
+	opts.wrap = wrap.value
+
+	opts.extraStates, err = statesToApplyAfterMove(string(maximize))
+	if err != nil {
+		return notMoved, err
+	}
+	if opts.scaleBy != "pixel" && opts.scaleBy != "physical" {
+		return notMoved, fmt.Errorf("unknown scale-by %q, expected pixel/physical", opts.scaleBy)
+	}
+
+	_, err = screenOrder(nil, opts.monitorOrder)
+	if err != nil {
+		return notMoved, err
+	}
+
+	if preserveFraction != "" {
+		opts.fractionGrid, err = parseFractionGrid(preserveFraction)
+		if err != nil {
+			return notMoved, err
+		}
+	}
+
+	opts.monitorScales, err = parseMonitorScales(monitorScales)
+	if err != nil {
+		return notMoved, err
+	}
+
+	err = checkScaleLimits(opts.minScale, opts.maxScale)
+	if err != nil {
+		return notMoved, err
+	}
+
+	if andDesktop != "" {
+		opts.desktopOffset, err = strconv.Atoi(andDesktop)
+		if err != nil {
+			return notMoved, fmt.Errorf("invalid and-desktop %q, expected +N or -N", andDesktop)
+		}
+	}
+
+	if confirm {
+		opts.confirmMs = int(confirmDuration.Milliseconds())
+	}
+
+	opts.focus, err = parseFocusMode(string(focusAfterMove))
+	if err != nil {
+		return notMoved, err
+	}
+
+	if keepAbove {
+		// Added along with any restored states once the window has moved
+		opts.extraStates = append(opts.extraStates, "_NET_WM_STATE_ABOVE")
+	}
+
+	if client {
+		path, err := socketPath()
+		if err != nil {
+			return notMoved, fmt.Errorf("Error finding daemon socket: %v", err)
+		}
+		reply, err := sendCommand(path, strings.Join(flag.Args(), " "))
+		if err != nil {
+			return notMoved, fmt.Errorf("Error talking to daemon: %v", err)
+		}
+		if strings.HasPrefix(reply, "error: ") {
+			return notMoved, errors.New(strings.TrimSpace(strings.TrimPrefix(reply, "error: ")))
+		}
+		fmt.Print(strings.TrimPrefix(reply, "ok\n"))
+		return moved, nil
+	}
 
 	X, err := xgbutil.NewConn()
 	if err != nil {
-		log.Fatalf("Error connecting to display: %v", err)
+		return notMoved, fmt.Errorf("Error connecting to display: %v", err)
 	}
 	defer X.Conn().Close()
 
-	active_window_id, err := ewmh.ActiveWindowGet(X)
+	if runAsDaemon {
+		err = runDaemon(X, backend, opts)
+		if err != nil {
+			return notMoved, fmt.Errorf("Daemon stopped: %v", err)
+		}
+		return moved, nil
+	}
+
+	screens, err := heads(X, backend)
+	if err != nil {
+		return notMoved, fmt.Errorf("Error getting list of monitors: %v", err)
+	}
+
+	if !shouldMove(screens) && !list && !query && !dumpEwmh && !nearest && !jsonOutput && !undo {
+		log.Printf("only one monitor detected; nothing to do")
+		return notMoved, nil
+	}
+
+	if evacuateSrc >= 0 {
+		for _, index := range []int{evacuateSrc, evacuateDst} {
+			err = validMonitor(screens, index)
+			if err != nil {
+				return notMoved, err
+			}
+		}
+		result, err := evacuate(X, opts, screens, evacuateSrc, evacuateDst)
+		if err != nil {
+			return notMoved, fmt.Errorf("Unable to evacuate monitor %d: %v", evacuateSrc, err)
+		}
+		return result, nil
+	}
+
+	if fromStdin {
+		ids, err := readWindowIDs(os.Stdin)
+		if err != nil {
+			if len(ids) == 0 {
+				return notMoved, err
+			}
+			// Move the windows that could be read anyway
+			log.Print(err)
+		}
+		return moveEach(ids, func(id xproto.Window) (moveResult, error) {
+			return moveOne(X, opts, xwindow.New(X, id), screens)
+		})
+	}
+
+	active_window, err := resolveTargetWindow(X, windowStr)
 	if err != nil {
-		log.Fatalf("Error getting active window: %v", err)
+		return notMoved, err
+	}
+
+	// Nothing is focused, e.g. on an empty desktop. Listing monitors still works without a window
+	if gotomonitor.IsNoWindow(X, active_window.Id) && !list {
+		log.Printf("No active window, nothing to do")
+		return notMoved, nil
+	}
+
+	if dumpEwmh {
+		info, err := dumpWindowInfo(X, active_window, screens)
+		if err != nil {
+			return notMoved, err
+		}
+		fmt.Print(info)
+		return moved, nil
+	}
+
+	if undo {
+		err = undoLastMove(X, active_window, opts.dryRun)
+		if err != nil {
+			return notMoved, fmt.Errorf("Unable to undo last move: %v", err)
+		}
+		return moved, nil
+	}
+
+	if query {
+		index, name, err := currentMonitor(X, active_window, screens, opts.overlapBasis)
+		if err != nil {
+			return notMoved, err
+		}
+		if name != "" {
+			fmt.Println(index, name)
+		} else {
+			fmt.Println(index)
+		}
+		return moved, nil
+	}
+
+	if list {
+		// Without a window there is no current monitor to mark
+		current := -1
+		source_rect, err := selectSourceRect(active_window, opts.overlapBasis)
+		if err == nil {
+			current = resolveSourceIndex(source_rect, screens)
+		}
+		fmt.Print(formatScreens(screens, current))
+		return moved, nil
 	}
 
-	active_window := xwindow.New(X, active_window_id)
 	current_geometry, err := active_window.DecorGeometry()
 	if err != nil {
-		log.Fatalf("Error getting active window geometry: %v", err)
+		return notMoved, fmt.Errorf("Error getting active window geometry: %v", err)
 	}
 
-	screens, err := xinerama.PhysicalHeads(X)
+	source_rect, err := selectSourceRect(active_window, opts.overlapBasis)
 	if err != nil {
-		log.Fatalf("Error getting list of monitors: %v", err)
+		return notMoved, fmt.Errorf("Error getting active window geometry: %v", err)
 	}
 
-	// Find monitor with largest overlap
-	index := xrect.LargestOverlap(current_geometry, screens)
-	if index == -1 {
-		log.Fatalf("Active window does not overlap any monitor")
+	// Find monitor with largest overlap, or the nearest if the window is off screen
+	index := resolveSourceIndex(source_rect, screens)
+
+	// The rest moves the window, leave alone windows that shouldn't be moved
+	if !jsonOutput && !all {
+		var skip string
+		opts, skip, err = prepareMove(X, opts, active_window)
+		if err != nil {
+			return notMoved, err
+		}
+		if skip != "" {
+			log.Printf("Not moving window %d, %s", active_window.Id, skip)
+			return notMoved, nil
+		}
 	}
-	screen_geometry := screens[index]
-	next_screen := find_next(screen_geometry, screens, parseDir(dirStr), wrap)
 
-	if next_screen == screen_geometry {
-		// Nothing to do
-		return
+	if nearest {
+		result, err := moveOntoNearest(X, opts, active_window, current_geometry, screens)
+		if err != nil {
+			return notMoved, fmt.Errorf("Unable to move active window: %v", err)
+		}
+		return result, nil
 	}
+	if index == -1 {
+		return notMoved, fmt.Errorf("No monitors to move the active window from")
+	}
+	debug.Printf("Window %v is on monitor %d %v", current_geometry, index, screens[index])
 
-	// Scale (if new screen is different size) and translate
-	relative_geometry := build_relative(current_geometry, screen_geometry)
-	next_geometry := build_absolute(relative_geometry, next_screen)
+	if all {
+		result, err := moveAll(X, opts, screens, index)
+		if err != nil {
+			return notMoved, fmt.Errorf("Unable to move windows: %v", err)
+		}
+		return result, nil
+	}
 
-	// Retrieve properties that must be removed prior to moving
-	// 3 NET_WM_STATE window properties prevent a windows from being moved across monitors:
-	//'_NET_WM_STATE_MAXIMIZED_HORZ' '_NET_WM_STATE_MAXIMIZED_VERT', '_NET_WM_STATE_FULLSCREEN'
-	state, err := ewmh.WmStateGet(X, active_window.Id)
+	next_index, err := targetScreen(X, opts, screens, index, current_geometry)
 	if err != nil {
-		log.Fatalf("Unable to retrieve active window's state: %v", err)
+		return notMoved, err
 	}
-	to_remove := make([]string, len(state))
-	for _, x := range state {
-		if x == "_NET_WM_STATE_MAXIMIZED_HORZ" ||
-			x == "_NET_WM_STATE_MAXIMIZED_VERT" ||
-			x == "_NET_WM_STATE_FULLSCREEN" {
-			to_remove = append(to_remove, x)
+
+	if jsonOutput {
+		report := buildReport(screens, active_window.Id, index, next_index)
+		out, err := json.Marshal(report)
+		if err != nil {
+			return notMoved, fmt.Errorf("Error encoding JSON report: %v", err)
 		}
+		fmt.Println(string(out))
+		return moved, nil
 	}
 
-	err = WmStateReqExtra2(*active_window, ewmh.StateRemove, Pager, to_remove...)
-	if err != nil {
-		log.Fatalf("Unable to update _NET_WM_STATE to make window moveable: %v", err)
+	if spanNext {
+		result, err := spanScreens(opts, active_window, screens, index, next_index)
+		if err != nil {
+			return notMoved, fmt.Errorf("Unable to stretch active window: %v", err)
+		}
+		return result, nil
 	}
 
-	// Move window
-	// TODO: xwindow.WMMoveResize has a bug in current version of xbgutil
-	err = WMMoveResize(*active_window, next_geometry.X(), next_geometry.Y(), next_geometry.Width(), next_geometry.Height())
-	if err != nil {
-		log.Fatalf("Unable to move active window: %v", err)
+	// Find the window to swap with before the active window lands on top of it
+	var other xproto.Window
+	if swap && next_index != index {
+		other, swap = topWindowOnScreen(X, screens, next_index)
 	}
 
-	// Restore maximized/fullscreen state
-	err = WmStateReqExtra2(*active_window, ewmh.StateAdd, Pager, to_remove...)
+	result, err = moveIfNeeded(X, opts, active_window, current_geometry, screens, index, next_index)
 	if err != nil {
-		log.Fatalf("Unable to restore _NET_WM_STATE after moving window: %v", err)
+		return notMoved, fmt.Errorf("Unable to move active window: %v", err)
+	}
+	if result == notMoved {
+		return result, nil
+	}
+
+	if swap {
+		other_window := xwindow.New(X, other)
+		other_geometry, err := other_window.DecorGeometry()
+		if err != nil {
+			return notMoved, fmt.Errorf("Error getting geometry of window to swap with: %v", err)
+		}
+		err = moveToScreen(X, swapOptions(opts), other_window, other_geometry, screens, next_index, index)
+		if err != nil {
+			return notMoved, fmt.Errorf("Unable to swap window: %v", err)
+		}
 	}
+	return result, nil
 }
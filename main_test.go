@@ -0,0 +1,891 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil/xrect"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+func TestParseDir(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    gotomonitor.Ordinal
+		wantErr bool
+	}{
+		{"", 0, true},
+		{"east", gotomonitor.East, false},
+		{"East", gotomonitor.East, false},
+		{"E", gotomonitor.East, false},
+		{"w", gotomonitor.West, false},
+		{"NORTH", gotomonitor.North, false},
+		{"down", gotomonitor.South, false},
+		{"south-west", gotomonitor.SouthWest, false},
+		{"NE", gotomonitor.NorthEast, false},
+		{"foo", 0, true},
+		{"Eat", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDir(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDir(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+		} else if err == nil && got != tt.want {
+			t.Errorf("parseDir(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseWrap(t *testing.T) {
+	tests := []struct {
+		in   string
+		want gotomonitor.Wrap
+	}{
+		{"all", gotomonitor.WrapAll},
+		{"true", gotomonitor.WrapAll},
+		{"none", gotomonitor.WrapNone},
+		{"false", gotomonitor.WrapNone},
+		{"Horizontal", gotomonitor.WrapHorizontal},
+		{"vertical", gotomonitor.WrapVertical},
+	}
+	for _, tt := range tests {
+		got, err := parseWrap(tt.in)
+		if err != nil {
+			t.Errorf("parseWrap(%q) error: %v", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("parseWrap(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	_, err := parseWrap("sideways")
+	if err == nil {
+		t.Errorf("parseWrap(%q) accepted an unknown wrap", "sideways")
+	}
+}
+
+func TestCheckDetachedValue(t *testing.T) {
+	tests := []struct {
+		args    []string
+		rest    []string
+		wantErr bool
+	}{
+		{[]string{"-wrap", "none"}, []string{"none"}, true},
+		{[]string{"--wrap", "horizontal"}, []string{"horizontal"}, true},
+		{[]string{"-maximize", "vert"}, []string{"vert"}, true},
+		{[]string{"-focus-after-move", "false"}, []string{"false"}, true},
+		// A bare flag followed by a direction is fine
+		{[]string{"-wrap", "east"}, []string{"east"}, false},
+		{[]string{"-wrap=none", "east"}, []string{"east"}, false},
+		{[]string{"-v", "none"}, []string{"none"}, false},
+		{[]string{"-wrap", "--", "none"}, []string{"none"}, false},
+		{[]string{"east"}, []string{"east"}, false},
+		{[]string{"-wrap"}, nil, false},
+		{nil, nil, false},
+	}
+	for _, tt := range tests {
+		err := checkDetachedValue(tt.args, tt.rest)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkDetachedValue(%q, %q) error = %v, want error %v", tt.args, tt.rest, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIsSticky(t *testing.T) {
+	tests := []struct {
+		state []string
+		want  bool
+	}{
+		{nil, false},
+		{[]string{"_NET_WM_STATE_MAXIMIZED_HORZ"}, false},
+		{[]string{"_NET_WM_STATE_STICKY"}, true},
+		{[]string{"_NET_WM_STATE_ABOVE", "_NET_WM_STATE_STICKY"}, true},
+	}
+	for _, tt := range tests {
+		if got := isSticky(tt.state); got != tt.want {
+			t.Errorf("isSticky(%v) = %v, want %v", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestOverrideRedirect(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   *xproto.GetWindowAttributesReply
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{"managed window", &xproto.GetWindowAttributesReply{OverrideRedirect: false}, nil, false, false},
+		{"menu", &xproto.GetWindowAttributesReply{OverrideRedirect: true}, nil, true, false},
+		{"closed window", nil, xproto.WindowError{NiceName: "Window"}, false, true},
+	}
+	for _, tt := range tests {
+		got, err := overrideRedirect(tt.reply, tt.err)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: overrideRedirect error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: overrideRedirect = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	tests := []struct {
+		name             string
+		overrideRedirect bool
+		types            []string
+		state            []string
+		moveSticky       bool
+		skip             bool
+	}{
+		{"normal window", false, nil, nil, false, false},
+		{"dialog", false, []string{"_NET_WM_WINDOW_TYPE_DIALOG"}, nil, false, false},
+		{"maximized window", false, nil, []string{"_NET_WM_STATE_MAXIMIZED_HORZ"}, false, false},
+		{"menu", true, nil, nil, false, true},
+		{"dock", false, []string{"_NET_WM_WINDOW_TYPE_DOCK"}, nil, false, true},
+		{"desktop", false, []string{"_NET_WM_WINDOW_TYPE_DESKTOP"}, nil, false, true},
+		{"sticky window", false, nil, []string{"_NET_WM_STATE_STICKY"}, false, true},
+		{"sticky window with -move-sticky", false, nil, []string{"_NET_WM_STATE_STICKY"}, true, false},
+		{"sticky dock with -move-sticky", false, []string{"_NET_WM_WINDOW_TYPE_DOCK"}, []string{"_NET_WM_STATE_STICKY"}, true, true},
+	}
+	for _, tt := range tests {
+		got := skipReason(tt.overrideRedirect, tt.types, tt.state, tt.moveSticky)
+		if (got != "") != tt.skip {
+			t.Errorf("%s: skipReason = %q, want skipped %v", tt.name, got, tt.skip)
+		}
+	}
+}
+
+func TestDedupeMirrored(t *testing.T) {
+	tests := []struct {
+		name          string
+		screens       []xrect.Rect
+		wantScreens   []xrect.Rect
+		wantOriginals []int
+	}{
+		{
+			"fully mirrored",
+			[]xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(0, 0, 1920, 1080)},
+			[]xrect.Rect{xrect.New(0, 0, 1920, 1080)},
+			[]int{0},
+		},
+		{
+			"mirrored onto a slightly smaller monitor",
+			[]xrect.Rect{xrect.New(1920, 0, 1920, 1080), xrect.New(0, 0, 1920, 1080), xrect.New(0, 0, 1920, 1050)},
+			[]xrect.Rect{xrect.New(1920, 0, 1920, 1080), xrect.New(0, 0, 1920, 1080)},
+			[]int{0, 1},
+		},
+		{
+			"partially overlapping",
+			[]xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(960, 0, 1920, 1080)},
+			[]xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(960, 0, 1920, 1080)},
+			[]int{0, 1},
+		},
+		{
+			"disjoint",
+			[]xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080)},
+			[]xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080)},
+			[]int{0, 1},
+		},
+	}
+	for _, tt := range tests {
+		got, originals := dedupeMirrored(tt.screens)
+		if !reflect.DeepEqual(got, tt.wantScreens) || !reflect.DeepEqual(originals, tt.wantOriginals) {
+			t.Errorf("%s: dedupeMirrored = %v %v, want %v %v", tt.name, got, originals, tt.wantScreens, tt.wantOriginals)
+		}
+	}
+}
+
+func TestFilterValidScreens(t *testing.T) {
+	screens := []xrect.Rect{
+		xrect.New(0, 0, 1920, 1080),
+		xrect.New(1920, 0, 0, 0),
+		xrect.New(1920, 0, 1280, 0),
+		xrect.New(1920, 0, 1280, 1024),
+	}
+	want := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1280, 1024)}
+	got := filterValidScreens(screens)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterValidScreens = %v, want %v", got, want)
+	}
+
+	// Scaling relative to what is left never divides by zero
+	geo := xrect.New(100, 100, 800, 600)
+	for _, screen := range got {
+		rel := gotomonitor.BuildRelative(geo, screen)
+		for _, f := range []float64{rel.X, rel.Y, rel.Width, rel.Height} {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				t.Errorf("BuildRelative(%v, %v) = %+v", geo, screen, rel)
+			}
+		}
+	}
+}
+
+func TestResolveSourceIndex(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080)}
+	tests := []struct {
+		name string
+		geo  xrect.Rect
+		want int
+	}{
+		{"overlapping both", xrect.New(1700, 100, 800, 600), 1},
+		{"off screen to the left", xrect.New(-3000, 100, 800, 600), 0},
+		{"off screen to the right", xrect.New(5000, 100, 800, 600), 1},
+	}
+	for _, tt := range tests {
+		if got := resolveSourceIndex(tt.geo, screens); got != tt.want {
+			t.Errorf("%s: resolveSourceIndex(%v) = %d, want %d", tt.name, tt.geo, got, tt.want)
+		}
+	}
+}
+
+func TestCheckScaleLimits(t *testing.T) {
+	tests := []struct {
+		minScale, maxScale float64
+		wantErr            bool
+	}{
+		{0, 0, false},
+		{0.5, 0, false},
+		{0, 1.5, false},
+		{0.5, 1.5, false},
+		{1, 1, false},
+		{-0.5, 0, true},
+		{0, -1.5, true},
+		{2, 1.5, true},
+	}
+	for _, tt := range tests {
+		err := checkScaleLimits(tt.minScale, tt.maxScale)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkScaleLimits(%v, %v) error = %v, want error %v", tt.minScale, tt.maxScale, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidMonitor(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080)}
+	tests := []struct {
+		index   int
+		wantErr bool
+	}{
+		{0, false},
+		{1, false},
+		{2, true},
+		{10, true},
+		{-1, true},
+	}
+	for _, tt := range tests {
+		err := validMonitor(screens, tt.index)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validMonitor(%d) error = %v, want error %v", tt.index, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveMonitorIndex(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080)}
+	order := []int{0, 1}
+	tests := []struct {
+		spec    string
+		want    int
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1", 1, false},
+		{"2", -1, true},
+		{"second", -1, true},
+	}
+	for _, tt := range tests {
+		got, err := resolveMonitor(tt.spec, 0, screens, order, true)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveMonitor(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+		} else if err == nil && got != tt.want {
+			t.Errorf("resolveMonitor(%q) = %d, want %d", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestScreenContainingPoint(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080)}
+	tests := []struct {
+		name string
+		x, y int
+		want int
+	}{
+		{"inside the first", 100, 100, 0},
+		{"inside the second", 2500, 500, 1},
+		{"on the shared edge", 1920, 500, 1},
+		{"last column of the first", 1919, 500, 0},
+		{"top left corner", 0, 0, 0},
+		{"past the right edge", 3840, 500, -1},
+		{"below all of them", 100, 1080, -1},
+		{"above all of them", 100, -1, -1},
+	}
+	for _, tt := range tests {
+		if got := screenContainingPoint(tt.x, tt.y, screens); got != tt.want {
+			t.Errorf("%s: screenContainingPoint(%d, %d) = %d, want %d", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestTargetWindowID(t *testing.T) {
+	active := func() (xproto.Window, error) { return 0x3a00004, nil }
+	// Only 0x1c00007 exists
+	exists := func(id xproto.Window) error {
+		if id != 0x1c00007 {
+			return xproto.WindowError{NiceName: "Window", BadValue: uint32(id)}
+		}
+		return nil
+	}
+	tests := []struct {
+		flagValue string
+		want      xproto.Window
+		wantErr   bool
+	}{
+		{"0x1c00007", 0x1c00007, false},
+		{"29360135", 0x1c00007, false},
+		{"", 0x3a00004, false},
+		{"bogus", 0, true},
+		{"0x1c00008", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := targetWindowID(tt.flagValue, active, exists)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("targetWindowID(%q) error = %v, want error %v", tt.flagValue, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("targetWindowID(%q) = %#x, want %#x", tt.flagValue, got, tt.want)
+		}
+	}
+}
+
+func TestFormatScreens(t *testing.T) {
+	screens := []xrect.Rect{
+		xrect.New(0, 0, 1920, 1080),
+		xrect.New(1920, 0, 2560, 1440),
+		xrect.New(-1280, 56, 1280, 1024),
+	}
+	want := "0  0,0 1920x1080\n" +
+		"1  1920,0 2560x1440 *current\n" +
+		"2  -1280,56 1280x1024\n"
+	if got := formatScreens(screens, 1); got != want {
+		t.Errorf("formatScreens = %q, want %q", got, want)
+	}
+
+	// Without a window there is no current monitor
+	if got := formatScreens(screens[:1], -1); got != "0  0,0 1920x1080\n" {
+		t.Errorf("formatScreens without a current monitor = %q", got)
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 2560, 1440)}
+	out, err := json.Marshal(buildReport(screens, 0x1c00007, 0, 1))
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+
+	var got LayoutReport
+	err = json.Unmarshal(out, &got)
+	if err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", out, err)
+	}
+	want := LayoutReport{
+		Screens: []ScreenReport{
+			{Index: 0, X: 0, Y: 0, Width: 1920, Height: 1080},
+			{Index: 1, X: 1920, Y: 0, Width: 2560, Height: 1440},
+		},
+		ActiveWindow: 0x1c00007,
+		Current:      0,
+		Next:         1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("report round tripped through %s = %+v, want %+v", out, got, want)
+	}
+
+	// Field names are what scripts read
+	var fields map[string]interface{}
+	err = json.Unmarshal(out, &fields)
+	if err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", out, err)
+	}
+	for _, name := range []string{"screens", "active_window", "current", "next"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("report %s has no %q field", out, name)
+		}
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	quiet := newLogger(false)
+	if quiet.Writer() != io.Discard {
+		t.Errorf("newLogger(false) writes to %v, want debug lines discarded", quiet.Writer())
+	}
+
+	verbose := newLogger(true)
+	if verbose.Writer() != os.Stderr {
+		t.Errorf("newLogger(true) writes to %v, want stderr", verbose.Writer())
+	}
+	if verbose.Prefix() != "debug: " {
+		t.Errorf("newLogger(true) prefix = %q, want %q", verbose.Prefix(), "debug: ")
+	}
+}
+
+func TestStatesToApplyAfterMove(t *testing.T) {
+	horz, vert := "_NET_WM_STATE_MAXIMIZED_HORZ", "_NET_WM_STATE_MAXIMIZED_VERT"
+	tests := []struct {
+		flag    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"false", nil, false},
+		{"horz", []string{horz}, false},
+		{"VERT", []string{vert}, false},
+		{"both", []string{horz, vert}, false},
+		{"true", []string{horz, vert}, false},
+		{"diagonal", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := statesToApplyAfterMove(tt.flag)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("statesToApplyAfterMove(%q) error = %v, want error %v", tt.flag, err, tt.wantErr)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("statesToApplyAfterMove(%q) = %q, want %q", tt.flag, got, tt.want)
+		}
+	}
+}
+
+func TestShouldMove(t *testing.T) {
+	one := []xrect.Rect{xrect.New(0, 0, 1920, 1080)}
+	two := append(one, xrect.New(1920, 0, 1920, 1080))
+	if shouldMove(nil) {
+		t.Errorf("shouldMove with no monitors = true")
+	}
+	if shouldMove(one) {
+		t.Errorf("shouldMove with a single monitor = true")
+	}
+	if !shouldMove(two) {
+		t.Errorf("shouldMove with two monitors = false")
+	}
+}
+
+func TestResolveRelativeMonitor(t *testing.T) {
+	tests := []struct {
+		current int
+		spec    string
+		wrap    bool
+		want    int
+		wantErr bool
+	}{
+		{0, "+1", false, 1, false},
+		{2, "-1", false, 1, false},
+		{0, "+3", false, 2, false},
+		{2, "+1", false, 2, false},
+		{0, "-1", false, 0, false},
+		{0, "+1", true, 1, false},
+		{2, "+1", true, 0, false},
+		{0, "-1", true, 2, false},
+		{1, "+3", true, 1, false},
+		{0, "1", false, -1, true},
+		{0, "+", false, -1, true},
+		{0, "+one", false, -1, true},
+		{0, "", true, -1, true},
+	}
+	for _, tt := range tests {
+		got, err := resolveRelativeMonitor(tt.current, tt.spec, 3, tt.wrap)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveRelativeMonitor(%d, %q, 3, %v) error = %v, want error %v", tt.current, tt.spec, tt.wrap, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("resolveRelativeMonitor(%d, %q, 3, %v) = %d, want %d", tt.current, tt.spec, tt.wrap, got, tt.want)
+		}
+	}
+	if _, err := resolveRelativeMonitor(0, "+1", 0, true); err == nil {
+		t.Errorf("resolveRelativeMonitor with no monitors didn't fail")
+	}
+}
+
+func TestIsMovableType(t *testing.T) {
+	tests := []struct {
+		types []string
+		want  bool
+	}{
+		{nil, true},
+		{[]string{"_NET_WM_WINDOW_TYPE_NORMAL"}, true},
+		{[]string{"_NET_WM_WINDOW_TYPE_DIALOG"}, true},
+		{[]string{"_NET_WM_WINDOW_TYPE_DOCK"}, false},
+		{[]string{"_NET_WM_WINDOW_TYPE_DESKTOP"}, false},
+		{[]string{"_NET_WM_WINDOW_TYPE_SPLASH"}, false},
+		// Types are in order of preference, any unmovable one is enough
+		{[]string{"_KDE_NET_WM_WINDOW_TYPE_OVERRIDE", "_NET_WM_WINDOW_TYPE_DOCK"}, false},
+	}
+	for _, tt := range tests {
+		if got := isMovableType(tt.types); got != tt.want {
+			t.Errorf("isMovableType(%q) = %v, want %v", tt.types, got, tt.want)
+		}
+	}
+}
+
+func TestMonitorOfGeometry(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 2560, 1440)}
+	tests := []struct {
+		geo  xrect.Rect
+		want int
+	}{
+		{xrect.New(100, 100, 800, 600), 0},
+		{xrect.New(2000, 100, 800, 600), 1},
+		// Mostly on the second monitor
+		{xrect.New(1800, 100, 800, 600), 1},
+		// Off screen, the nearest monitor
+		{xrect.New(5000, 100, 800, 600), 1},
+	}
+	for _, tt := range tests {
+		got, err := monitorOfGeometry(tt.geo, screens)
+		if err != nil || got != tt.want {
+			t.Errorf("monitorOfGeometry(%v) = %d, %v, want %d", tt.geo, got, err, tt.want)
+		}
+	}
+	if _, err := monitorOfGeometry(xrect.New(0, 0, 800, 600), nil); err == nil {
+		t.Errorf("monitorOfGeometry with no monitors didn't fail")
+	}
+}
+
+func TestParseDirRejectsMisspellings(t *testing.T) {
+	for _, in := range []string{"Eat", "Esat", "Wset", "Nroth", "Sout", "East ", "EastWest", "rigth"} {
+		if got, err := parseDir(in); err == nil {
+			t.Errorf("parseDir(%q) = %v, want an error rather than a direction", in, got)
+		}
+	}
+}
+
+func TestParseDirAliases(t *testing.T) {
+	tests := []struct {
+		in   string
+		want gotomonitor.Ordinal
+	}{
+		{"left", gotomonitor.West},
+		{"right", gotomonitor.East},
+		{"up", gotomonitor.North},
+		{"down", gotomonitor.South},
+		{"LEFT", gotomonitor.West},
+		{"Right", gotomonitor.East},
+		{"West", gotomonitor.West},
+		{"East", gotomonitor.East},
+		{"North", gotomonitor.North},
+		{"South", gotomonitor.South},
+	}
+	for _, tt := range tests {
+		got, err := parseDir(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseDir(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestSourceRect(t *testing.T) {
+	// A window with a tall title bar: the frame reaches up onto the monitor above, the client area doesn't
+	frame := func() (xrect.Rect, error) { return xrect.New(100, 800, 800, 400), nil }
+	client := func() (xrect.Rect, error) { return xrect.New(102, 1100, 796, 98), nil }
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(0, 1080, 1920, 1080)}
+
+	tests := []struct {
+		basis     string
+		wantIndex int
+		wantErr   bool
+	}{
+		{"frame", 0, false},
+		{"client", 1, false},
+		{"window", -1, true},
+	}
+	for _, tt := range tests {
+		geo, err := sourceRect(tt.basis, frame, client)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("sourceRect(%q) error = %v, want error %v", tt.basis, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := resolveSourceIndex(geo, screens); got != tt.wantIndex {
+			t.Errorf("sourceRect(%q) = %v on monitor %d, want monitor %d", tt.basis, geo, got, tt.wantIndex)
+		}
+	}
+
+	failing := func() (xrect.Rect, error) { return nil, errors.New("BadWindow") }
+	if _, err := sourceRect("client", frame, failing); err == nil {
+		t.Errorf("sourceRect didn't return the error reading the client geometry")
+	}
+}
+
+func TestParseGravity(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int
+		wantErr bool
+	}{
+		{"NorthWest", xproto.GravityNorthWest, false},
+		{"nw", xproto.GravityNorthWest, false},
+		{"center", xproto.GravityCenter, false},
+		{"SouthEast", xproto.GravitySouthEast, false},
+		{"static", xproto.GravityStatic, false},
+		{"forget", xproto.GravityBitForget, false},
+		{"", 0, true},
+		{"middle", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseGravity(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGravity(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("parseGravity(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseStripStates(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		// The flag's default
+		{strings.Join(gotomonitor.BlockingStates, ","), gotomonitor.BlockingStates},
+		{"shaded, maximized_horz", []string{"_NET_WM_STATE_SHADED", "_NET_WM_STATE_MAXIMIZED_HORZ"}},
+		{"_NET_WM_STATE_FULLSCREEN,,", []string{"_NET_WM_STATE_FULLSCREEN"}},
+		// Nothing blocks moves
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := parseStripStates(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStripStates(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResolveDirection(t *testing.T) {
+	tests := []struct {
+		name    string
+		flagVal string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		// -direction isn't given, so it is still its default
+		{"neither", "East", nil, "East", false},
+		{"flag only", "West", nil, "West", false},
+		{"positional only", "East", []string{"north"}, "north", false},
+		{"both, positional wins", "West", []string{"south"}, "south", false},
+		{"too many", "East", []string{"south", "-wrap"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := resolveDirection(tt.flagVal, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: resolveDirection(%q, %q) error = %v, want error %v", tt.name, tt.flagVal, tt.args, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("%s: resolveDirection(%q, %q) = %q, want %q", tt.name, tt.flagVal, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestScreenOrder(t *testing.T) {
+	// A 2x2 grid listed bottom right, bottom left, top right, top left
+	screens := []xrect.Rect{
+		xrect.New(1920, 1080, 1920, 1080),
+		xrect.New(0, 1080, 1920, 1080),
+		xrect.New(1920, 0, 1920, 1080),
+		xrect.New(0, 0, 1920, 1080),
+	}
+	tests := []struct {
+		order   string
+		want    []int
+		wantErr bool
+	}{
+		{"", []int{0, 1, 2, 3}, false},
+		// Ties keep the order the monitors were listed in
+		{"x", []int{1, 3, 0, 2}, false},
+		{"y", []int{2, 3, 0, 1}, false},
+		{"xy", []int{3, 1, 2, 0}, false},
+		{"yx", []int{3, 2, 1, 0}, false},
+		{"z", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := screenOrder(screens, tt.order)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("screenOrder(%q) error = %v, want error %v", tt.order, err, tt.wantErr)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("screenOrder(%q) = %v, want %v", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestParseDirSequence(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []gotomonitor.Ordinal
+		wantErr bool
+	}{
+		{"East", []gotomonitor.Ordinal{gotomonitor.East}, false},
+		{"South,East", []gotomonitor.Ordinal{gotomonitor.South, gotomonitor.East}, false},
+		{"down, right, up", []gotomonitor.Ordinal{gotomonitor.South, gotomonitor.East, gotomonitor.North}, false},
+		{"South,", nil, true},
+		{",East", nil, true},
+		{"South,,East", nil, true},
+		{"South,Eat", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDirSequence(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDirSequence(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDirSequence(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseFractionGrid(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"1/2", 2, false},
+		{"1/3", 3, false},
+		{"1/4", 4, false},
+		{"2/3", 0, true},
+		{"1/0", 0, true},
+		{"3", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFractionGrid(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFractionGrid(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("parseFractionGrid(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLargestScreen(t *testing.T) {
+	tests := []struct {
+		name    string
+		screens []xrect.Rect
+		want    int
+	}{
+		{"no screens", nil, -1},
+		{"largest last", []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 3840, 2160)}, 1},
+		// 2560x1440 and 1440x2560 have the same area
+		{"tie", []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 2560, 1440), xrect.New(4480, 0, 1440, 2560)}, 1},
+	}
+	for _, tt := range tests {
+		if got := largestScreen(tt.screens); got != tt.want {
+			t.Errorf("%s: largestScreen = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSmallestScreen(t *testing.T) {
+	tests := []struct {
+		name    string
+		screens []xrect.Rect
+		want    int
+	}{
+		{"no screens", nil, -1},
+		{"smallest last", []xrect.Rect{xrect.New(0, 0, 3840, 2160), xrect.New(3840, 0, 1920, 1080)}, 1},
+		{"tie", []xrect.Rect{xrect.New(0, 0, 2560, 1440), xrect.New(2560, 0, 1280, 1024), xrect.New(3840, 0, 1024, 1280)}, 1},
+		// A zero size head would be the smallest, but isn't listed
+		{"zero size head", filterValidScreens([]xrect.Rect{
+			xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 0, 0), xrect.New(1920, 0, 1280, 1024),
+		}), 1},
+	}
+	for _, tt := range tests {
+		if got := smallestScreen(tt.screens); got != tt.want {
+			t.Errorf("%s: smallestScreen = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyDirectionEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want string
+	}{
+		{"unset", "", nil, "East"},
+		{"set", "West", nil, "West"},
+		{"flag overrides", "West", []string{"-direction", "North"}, "North"},
+		{"flag without env", "", []string{"-direction", "South"}, "South"},
+	}
+	for _, tt := range tests {
+		t.Setenv("GO_TO_MONITOR_DIRECTION", tt.env)
+		flags := flag.NewFlagSet("go-to-monitor", flag.ContinueOnError)
+		dir := flags.String("direction", "East", "")
+		if err := flags.Parse(tt.args); err != nil {
+			t.Fatalf("%s: parsing %q: %v", tt.name, tt.args, err)
+		}
+		if err := applyDirectionEnv(flags); err != nil {
+			t.Errorf("%s: applyDirectionEnv error: %v", tt.name, err)
+		}
+		if *dir != tt.want {
+			t.Errorf("%s: direction = %q, want %q", tt.name, *dir, tt.want)
+		}
+	}
+}
+
+func TestWindowMonitor(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 2560, 1440)}
+	geometry := func(geo xrect.Rect) func() (xrect.Rect, error) {
+		return func() (xrect.Rect, error) { return geo, nil }
+	}
+	tests := []struct {
+		name string
+		geo  xrect.Rect
+		want int
+	}{
+		{"editor on the left", xrect.New(0, 0, 1920, 1080), 0},
+		{"editor spanning both, mostly right", xrect.New(1000, 0, 3000, 1080), 1},
+	}
+	for _, tt := range tests {
+		got, err := windowMonitor(geometry(tt.geo), screens)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: windowMonitor(%v) = %d, %v, want %d", tt.name, tt.geo, got, err, tt.want)
+		}
+	}
+
+	closed := func() (xrect.Rect, error) {
+		return nil, xproto.WindowError{NiceName: "Window", BadValue: 0x1e00004}
+	}
+	if _, err := windowMonitor(closed, screens); err == nil {
+		t.Errorf("windowMonitor of a closed window didn't fail")
+	}
+}
+
+func TestParseWindowID(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    xproto.Window
+		wantErr bool
+	}{
+		{"0x1e00004", 0x1e00004, false},
+		{"31457284", 31457284, false},
+		{"editor", 0, true},
+		{"0x100000000", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseWindowID(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseWindowID(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("parseWindowID(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
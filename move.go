@@ -0,0 +1,511 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+// How a window is moved, set from the command line
+type options struct {
+	// Choosing the target monitor
+	// Directions to move in, one after the other
+	dirs        []gotomonitor.Ordinal
+	wrap        gotomonitor.Wrap
+	minOverlap  float64
+	monitor     string
+	toCursor    bool
+	output      string
+	outputRegex string
+	toWindow    string
+	primary     bool
+	cycle       bool
+	toLargest   bool
+	toSmallest  bool
+	// Order of monitors for -cycle and relative -monitor, see screenOrder
+	monitorOrder string
+	// Whether the window's monitor is found from its frame or client area, see sourceRect
+	overlapBasis string
+
+	// Choosing windows to move
+	// Move sticky windows too, keeping them sticky
+	moveSticky bool
+	// Batch modes move windows on every desktop
+	allDesktops bool
+
+	// Placing the window on the target monitor
+	respectStruts bool
+	snap          string
+	center        bool
+	centerCursor  bool
+	keepSize      bool
+	integerScale  bool
+	fractionGrid  int
+	minScale      float64
+	maxScale      float64
+	monitorScales map[string]float64
+	scaleBy       string
+	resizePercent int
+	gap           int
+	edgeMargin    int
+	extraStates   []string
+	noRestore     bool
+
+	// Before the move, milliseconds to highlight the target monitor for
+	confirmMs int
+
+	// After the move
+	dryRun        bool
+	raise         bool
+	focus         focusMode
+	warp          bool
+	notify        bool
+	followDesktop bool
+	desktopOffset int
+}
+
+// Whether the target monitor is found by searching in opts.dirs rather than picked some other way
+func (opts options) directional() bool {
+	return opts.monitor == "" && opts.output == "" && opts.outputRegex == "" && opts.toWindow == "" &&
+		!opts.toCursor && !opts.primary && !opts.cycle && !opts.toLargest && !opts.toSmallest
+}
+
+// Options for the window -swap moves back onto the active window's monitor. It is placed the same way as the active
+// window, but is left on its desktop
+func swapOptions(opts options) options {
+	swap_opts := opts
+	// Only the active window follows the pointer and takes focus, focusing the partner would take it from the window
+	// the user just moved
+	swap_opts.raise, swap_opts.warp, swap_opts.focus = false, false, focusNever
+	swap_opts.desktopOffset, swap_opts.followDesktop = 0, false
+	// prepareMove adds _NET_WM_STATE_STICKY for a sticky active window, and -maximize and -keep-above are about the
+	// active window too
+	swap_opts.extraStates = nil
+	// The notification and -confirm overlay are for the active window's move
+	swap_opts.notify, swap_opts.confirmMs = false, 0
+	return swap_opts
+}
+
+// Index of the monitor a window with geometry geo on screens[index] should be moved to
+func targetScreen(X *xgbutil.XUtil, opts options, screens []xrect.Rect, index int, geo xrect.Rect) (int, error) {
+	switch {
+	case opts.monitor != "":
+		order, err := screenOrder(screens, opts.monitorOrder)
+		if err != nil {
+			return -1, err
+		}
+		return resolveMonitor(opts.monitor, index, screens, order, opts.wrap != gotomonitor.WrapNone)
+	case opts.toCursor:
+		next_index, err := pointerScreen(X, screens)
+		if err != nil {
+			return -1, fmt.Errorf("error finding monitor under pointer: %v", err)
+		}
+		return next_index, nil
+	case opts.output != "":
+		monitors, err := namedMonitors(X)
+		if err != nil {
+			return -1, fmt.Errorf("error getting list of outputs: %v", err)
+		}
+		monitor, err := findMonitor(monitors, opts.output)
+		if err != nil {
+			return -1, err
+		}
+		next_index := matchScreen(monitor.Geom, screens)
+		if next_index == -1 {
+			return -1, fmt.Errorf("output %s at %v does not match any monitor", monitor.Name, monitor.Geom)
+		}
+		return next_index, nil
+	case opts.outputRegex != "":
+		monitors, err := namedMonitors(X)
+		if err != nil {
+			return -1, fmt.Errorf("error getting list of outputs: %v", err)
+		}
+		i, err := matchMonitor(monitors, opts.outputRegex)
+		if err != nil {
+			return -1, err
+		}
+		next_index := matchScreen(monitors[i].Geom, screens)
+		if next_index == -1 {
+			return -1, fmt.Errorf("output %s at %v does not match any monitor", monitors[i].Name, monitors[i].Geom)
+		}
+		return next_index, nil
+	case opts.toWindow != "":
+		id, err := parseWindowID(opts.toWindow)
+		if err != nil {
+			return -1, err
+		}
+		next_index, err := monitorOfWindow(X, xwindow.New(X, id), screens, opts.overlapBasis)
+		if err != nil {
+			return -1, fmt.Errorf("error finding monitor of window %s: %v", opts.toWindow, err)
+		}
+		return next_index, nil
+	case opts.primary:
+		next_index, err := primaryScreenIndex(X, screens)
+		if err != nil {
+			return -1, fmt.Errorf("error finding primary monitor: %v", err)
+		}
+		if next_index == -1 {
+			log.Printf("Warning: no primary monitor reported, using monitor 0")
+			next_index = 0
+		}
+		return next_index, nil
+	case opts.cycle:
+		order := gotomonitor.CycleOrder(screens)
+		if opts.monitorOrder != "" {
+			var err error
+			order, err = screenOrder(screens, opts.monitorOrder)
+			if err != nil {
+				return -1, err
+			}
+		}
+		position := 0
+		for i, screen := range order {
+			if screen == index {
+				position = i
+			}
+		}
+		return order[gotomonitor.NextCyclic(position, len(order))], nil
+	case opts.toLargest:
+		return largestScreen(screens), nil
+	case opts.toSmallest:
+		return smallestScreen(screens), nil
+	default:
+		next_index := index
+		for _, dir := range opts.dirs {
+			search := gotomonitor.Search{Dir: dir, Wrap: opts.wrap, Near: geo, MinOverlap: opts.minOverlap}
+			previous := next_index
+			next_index = search.Find(previous, screens)
+			// Later moves search from where the window would be after this one
+			geo = gotomonitor.Scale(geo, screens[previous], screens[next_index])
+		}
+		return next_index, nil
+	}
+}
+
+// Whether a window is focused after it has moved, set by -focus-after-move
+type focusMode int
+
+const (
+	// Focus the window if it had focus before moving, or it was raised
+	focusPreserve focusMode = iota
+	focusAlways
+	focusNever
+)
+
+func parseFocusMode(s string) (focusMode, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return focusPreserve, nil
+	case "true":
+		return focusAlways, nil
+	case "false":
+		return focusNever, nil
+	default:
+		return focusPreserve, fmt.Errorf("invalid focus-after-move %q, expected true/false", s)
+	}
+}
+
+// Whether to focus a window after moving it, given whether it was the active window beforehand and whether it was raised
+func (m focusMode) focus(wasActive, raised bool) bool {
+	switch m {
+	case focusAlways:
+		return true
+	case focusNever:
+		return false
+	default:
+		return wasActive || raised
+	}
+}
+
+// Calls raise if -raise is set, then focus if the focus mode says a window that was the active window (or not) should
+// be focused after moving
+func raiseAndFocus(opts options, wasActive bool, raise, focus func() error) error {
+	if opts.raise {
+		err := raise()
+		if err != nil {
+			return fmt.Errorf("unable to raise window: %w", err)
+		}
+	}
+
+	if opts.focus.focus(wasActive, opts.raise) {
+		err := focus()
+		if err != nil {
+			return fmt.Errorf("unable to focus window: %w", err)
+		}
+	}
+	return nil
+}
+
+// Whether a window was moved, scripts can tell from the exit code
+type moveResult int
+
+const (
+	moved moveResult = iota
+	notMoved
+)
+
+// Exit code for a move that didn't fail, 0 if the window moved and 2 if there was nothing to do
+func (r moveResult) exitCode() int {
+	if r == notMoved {
+		return 2
+	}
+	return 0
+}
+
+// Moves a window with geometry geo from screens[index] to screens[next_index], unless that is where it already is
+func moveIfNeeded(X *xgbutil.XUtil, opts options, win *xwindow.Window, geo xrect.Rect, screens []xrect.Rect, index, next_index int) (moveResult, error) {
+	if next_index == index {
+		debug.Printf("No monitor found to move to, leaving window on monitor %d", index)
+		return notMoved, nil
+	}
+	err := moveToScreen(X, opts, win, geo, screens, index, next_index)
+	if err != nil {
+		return notMoved, err
+	}
+	return moved, nil
+}
+
+// Moves a window with geometry geo the least distance needed to put it entirely on the monitor it is on, or nearest to
+func moveOntoNearest(X *xgbutil.XUtil, opts options, win *xwindow.Window, geo xrect.Rect, screens []xrect.Rect) (moveResult, error) {
+	index := gotomonitor.NearestScreen(geo, screens)
+	if index == -1 {
+		return notMoved, fmt.Errorf("no monitors")
+	}
+
+	target_area := screens[index]
+	if opts.respectStruts {
+		var err error
+		target_area, err = gotomonitor.WorkArea(X, target_area)
+		if err != nil {
+			return notMoved, fmt.Errorf("error getting work area of monitor: %w", err)
+		}
+	}
+
+	next_geometry := gotomonitor.ClampToScreen(geo, target_area)
+	if gotomonitor.SameRect(next_geometry, geo) {
+		debug.Printf("Window %d is already entirely on monitor %d", win.Id, index)
+		return notMoved, nil
+	}
+
+	if opts.dryRun {
+		log.Printf("Would move window %d onto monitor %d %v, new geometry %v", win.Id, index, screens[index], next_geometry)
+		return moved, nil
+	}
+	err := gotomonitor.MoveWindow(win, next_geometry, opts.extraStates...)
+	if err != nil {
+		return notMoved, fmt.Errorf("unable to move window: %w", err)
+	}
+	return moved, nil
+}
+
+// Top left corner of a w by h window centered on px,py, moved as little as possible to keep it on screen
+func positionAtPoint(w, h, px, py int, screen xrect.Rect) (x, y int) {
+	geo := gotomonitor.ClampToScreen(xrect.New(px-w/2, py-h/2, w, h), screen)
+	return geo.X(), geo.Y()
+}
+
+// Stretches a window across screens[index] and screens[next_index], the bounding box of the two
+func spanScreens(opts options, win *xwindow.Window, screens []xrect.Rect, index, next_index int) (moveResult, error) {
+	if next_index == index {
+		debug.Printf("No monitor found to span, leaving window on monitor %d", index)
+		return notMoved, nil
+	}
+
+	next_geometry := gotomonitor.ApplyGap(gotomonitor.UnionRect(screens[index], screens[next_index]), opts.gap)
+	if opts.dryRun {
+		log.Printf("Would stretch window %d across monitors %d and %d, new geometry %v", win.Id, index, next_index, next_geometry)
+		return moved, nil
+	}
+	// Maximizing again would shrink the window back onto a single monitor
+	err := gotomonitor.MoveWindowUnrestored(win, next_geometry, opts.extraStates...)
+	if err != nil {
+		return notMoved, fmt.Errorf("unable to move window: %w", err)
+	}
+	return moved, nil
+}
+
+// Rect pct percent of the size of screen centered on it, pct is clamped to 1-100
+func percentRect(pct int, screen xrect.Rect) xrect.Rect {
+	if pct < 1 {
+		pct = 1
+	}
+	if pct > 100 {
+		pct = 100
+	}
+	width := screen.Width() * pct / 100
+	height := screen.Height() * pct / 100
+	x, y := gotomonitor.CenterOnScreen(width, height, screen)
+	return xrect.New(x, y, width, height)
+}
+
+// Moves a window with geometry geo from screens[index] to screens[next_index]
+func moveToScreen(X *xgbutil.XUtil, opts options, win *xwindow.Window, geo xrect.Rect, screens []xrect.Rect, index, next_index int) error {
+	screen_geometry := screens[index]
+	next_screen := screens[next_index]
+
+	// Region the window is scaled relative to, larger than the monitor if the window spans several monitors
+	source_area := gotomonitor.SourceContainer(geo, screens)
+	if !gotomonitor.SameRect(source_area, screen_geometry) {
+		debug.Printf("Window spans several monitors %v", source_area)
+	}
+
+	// Region of the target monitor the window is placed in
+	target_area := next_screen
+	if opts.respectStruts {
+		var err error
+		target_area, err = gotomonitor.WorkArea(X, next_screen)
+		if err != nil {
+			return fmt.Errorf("error getting work area of monitor: %w", err)
+		}
+		debug.Printf("Work area of target monitor is %v", target_area)
+	}
+
+	var next_geometry xrect.Rect
+	if opts.snap != "" {
+		var err error
+		next_geometry, err = gotomonitor.SnapRect(opts.snap, target_area)
+		if err != nil {
+			return err
+		}
+		if opts.resizePercent > 0 {
+			next_geometry = percentRect(opts.resizePercent, next_geometry)
+		}
+	} else if opts.resizePercent > 0 {
+		next_geometry = percentRect(opts.resizePercent, target_area)
+	} else if opts.center {
+		x, y := gotomonitor.CenterOnScreen(geo.Width(), geo.Height(), target_area)
+		next_geometry = xrect.New(x, y, geo.Width(), geo.Height())
+	} else if opts.keepSize {
+		next_geometry = gotomonitor.TranslateOnly(geo, source_area, target_area)
+	} else {
+		var err error
+		next_geometry, err = scaleToScreen(X, opts, geo, source_area, target_area, screen_geometry, next_screen)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.centerCursor {
+		px, py, err := pointerPosition(X)
+		if err != nil {
+			return fmt.Errorf("error getting pointer position: %w", err)
+		}
+		x, y := positionAtPoint(next_geometry.Width(), next_geometry.Height(), px, py, target_area)
+		next_geometry = xrect.New(x, y, next_geometry.Width(), next_geometry.Height())
+	}
+	if opts.edgeMargin > 0 && opts.directional() && len(opts.dirs) > 0 {
+		last_dir := opts.dirs[len(opts.dirs)-1]
+		next_geometry = gotomonitor.EdgeInset(next_geometry, target_area, last_dir, opts.edgeMargin)
+	}
+	next_geometry = gotomonitor.ClampToScreen(next_geometry, target_area)
+	next_geometry = gotomonitor.ApplyGap(next_geometry, opts.gap)
+	debug.Printf("Moving window %d to monitor %d %v, new geometry %v", win.Id, next_index, next_screen, next_geometry)
+
+	if opts.dryRun {
+		log.Printf("Would move window %d from monitor %d %v to monitor %d %v, new geometry %v",
+			win.Id, index, screen_geometry, next_index, next_screen, next_geometry)
+		return nil
+	}
+
+	if opts.confirmMs > 0 {
+		err := flashMonitor(X, next_screen, opts.confirmMs)
+		if err != nil {
+			log.Printf("Warning: unable to highlight target monitor: %v", err)
+		}
+	}
+
+	// Some window managers drop focus from a window when it moves
+	active, _ := ewmh.ActiveWindowGet(X)
+	was_active := active == win.Id
+
+	undo_state := undoStateFor(win, geo)
+	// A window without _NET_WM_STATE has no states set
+	state, _ := ewmh.WmStateGet(X, win.Id)
+	var err error
+	switch {
+	case opts.noRestore:
+		err = gotomonitor.MoveWindowUnrestored(win, next_geometry, opts.extraStates...)
+	case gotomonitor.FillsMonitor(state):
+		debug.Printf("Window is maximized, leaving its size to the window manager")
+		err = gotomonitor.MoveMaximizedWindow(win, target_area, opts.extraStates...)
+	default:
+		err = gotomonitor.MoveWindow(win, next_geometry, opts.extraStates...)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to move window: %w", err)
+	}
+	err = recordMove(X, undo_state)
+	if err != nil {
+		log.Printf("Warning: unable to save undo state: %v", err)
+	}
+
+	raise := func() error { return gotomonitor.Raise(X, win) }
+	focus := func() error { return gotomonitor.Focus(X, win) }
+	err = raiseAndFocus(opts, was_active, raise, focus)
+	if err != nil {
+		return err
+	}
+
+	if opts.warp {
+		x, y := gotomonitor.PointerTarget(next_geometry)
+		err = warpPointer(X, x, y)
+		if err != nil {
+			return fmt.Errorf("unable to warp pointer: %w", err)
+		}
+	}
+
+	if opts.desktopOffset != 0 {
+		err = shiftDesktop(X, win, opts.desktopOffset, opts.wrap != gotomonitor.WrapNone)
+		if err != nil {
+			return fmt.Errorf("unable to change window's desktop: %w", err)
+		}
+	}
+
+	if opts.followDesktop {
+		err = followDesktop(X, win)
+		if err != nil {
+			return fmt.Errorf("unable to follow window to its desktop: %w", err)
+		}
+	}
+
+	if opts.notify {
+		err = notifyMove(monitorName(X, next_screen), next_index)
+		if err != nil {
+			log.Printf("Warning: unable to show notification: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Scales a window with geometry geo from source_area to target_area however the options ask, growing or shrinking
+// it by no more than -max-scale and -min-scale allow. screen_geometry and next_screen are the monitors themselves
+func scaleToScreen(X *xgbutil.XUtil, opts options, geo, source_area, target_area, screen_geometry, next_screen xrect.Rect) (xrect.Rect, error) {
+	var next_geometry xrect.Rect
+	if opts.integerScale {
+		next_geometry = gotomonitor.IntegerScale(geo, source_area, target_area)
+	} else if opts.scaleBy == "physical" {
+		src, src_ok := monitorAt(X, screen_geometry)
+		dst, dst_ok := monitorAt(X, next_screen)
+		if !src_ok || !dst_ok {
+			return nil, fmt.Errorf("unable to find the physical size of the monitors, RandR does not list them")
+		}
+		next_geometry = physicalScale(geo, source_area, target_area, src, dst)
+	} else if len(opts.monitorScales) > 0 {
+		src := gotomonitor.ScaledRect{Rect: source_area, Scale: monitorScale(X, opts.monitorScales, screen_geometry)}
+		dst := gotomonitor.ScaledRect{Rect: target_area, Scale: monitorScale(X, opts.monitorScales, next_screen)}
+		next_geometry = gotomonitor.ScaleDPI(geo, src, dst)
+	} else if opts.fractionGrid > 0 {
+		rgeo := gotomonitor.SnapFraction(gotomonitor.BuildRelative(geo, source_area), opts.fractionGrid)
+		next_geometry = gotomonitor.BuildAbsolute(rgeo, target_area)
+	} else {
+		next_geometry = gotomonitor.Scale(geo, source_area, target_area)
+	}
+	return gotomonitor.LimitScale(geo, next_geometry, opts.minScale, opts.maxScale), nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+func TestMoveResultExitCode(t *testing.T) {
+	if got := moved.exitCode(); got != 0 {
+		t.Errorf("moved.exitCode() = %d, want 0", got)
+	}
+	if got := notMoved.exitCode(); got != 2 {
+		t.Errorf("notMoved.exitCode() = %d, want 2", got)
+	}
+}
+
+func TestMoveResult(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080)}
+	// Neither X nor the window's geometry are needed to decide these
+	win := xwindow.New(nil, 1)
+	dryRun := options{dryRun: true}
+
+	run := func(name string, f func() (moveResult, error), want moveResult, wantErr bool) {
+		got, err := f()
+		if (err != nil) != wantErr {
+			t.Errorf("%s: error = %v, want error %v", name, err, wantErr)
+		}
+		if got != want {
+			t.Errorf("%s: result = %v, want %v", name, got, want)
+		}
+	}
+
+	run("no monitor in the direction", func() (moveResult, error) {
+		return moveIfNeeded(nil, dryRun, win, xrect.New(100, 100, 800, 600), screens, 0, 0)
+	}, notMoved, false)
+	run("already on its monitor", func() (moveResult, error) {
+		return moveOntoNearest(nil, dryRun, win, xrect.New(100, 100, 800, 600), screens)
+	}, notMoved, false)
+	run("hanging off its monitor", func() (moveResult, error) {
+		return moveOntoNearest(nil, dryRun, win, xrect.New(1500, 100, 800, 600), screens)
+	}, moved, false)
+	run("no monitors to move onto", func() (moveResult, error) {
+		return moveOntoNearest(nil, dryRun, win, xrect.New(100, 100, 800, 600), nil)
+	}, notMoved, true)
+	run("no monitor to span", func() (moveResult, error) {
+		return spanScreens(dryRun, win, screens, 1, 1)
+	}, notMoved, false)
+	run("spanning two monitors", func() (moveResult, error) {
+		return spanScreens(dryRun, win, screens, 0, 1)
+	}, moved, false)
+}
+
+// Records the raise and focus requests that would have been sent to the window manager
+type fakeRaiseSink struct {
+	requests []string
+}
+
+func (f *fakeRaiseSink) raise() error {
+	f.requests = append(f.requests, "raise")
+	return nil
+}
+
+func (f *fakeRaiseSink) focus() error {
+	f.requests = append(f.requests, "focus")
+	return nil
+}
+
+func TestRaiseAndFocus(t *testing.T) {
+	tests := []struct {
+		name      string
+		raise     bool
+		focus     focusMode
+		wasActive bool
+		want      []string
+	}{
+		{"default keeps focus", false, focusPreserve, true, []string{"focus"}},
+		{"default leaves an unfocused window", false, focusPreserve, false, nil},
+		{"raise focuses too", true, focusPreserve, false, []string{"raise", "focus"}},
+		{"raise without focus", true, focusNever, true, []string{"raise"}},
+		{"always focus", false, focusAlways, false, []string{"focus"}},
+	}
+	for _, tt := range tests {
+		sink := &fakeRaiseSink{}
+		opts := options{raise: tt.raise, focus: tt.focus}
+		err := raiseAndFocus(opts, tt.wasActive, sink.raise, sink.focus)
+		if err != nil {
+			t.Errorf("%s: raiseAndFocus error: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(sink.requests, tt.want) {
+			t.Errorf("%s: requests sent = %q, want %q", tt.name, sink.requests, tt.want)
+		}
+	}
+}
+
+func TestRaiseAndFocusError(t *testing.T) {
+	sink := &fakeRaiseSink{}
+	failing := func() error { return errors.New("connection closed") }
+	err := raiseAndFocus(options{raise: true}, true, failing, sink.focus)
+	if err == nil {
+		t.Errorf("raiseAndFocus with a failing raise returned no error")
+	}
+	if len(sink.requests) != 0 {
+		t.Errorf("raiseAndFocus went on to send %q after raising failed", sink.requests)
+	}
+}
+
+func TestDryRunDoesNotWarp(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1920, 1080)}
+	// Without an X connection, warping the pointer would crash
+	opts := options{dryRun: true, warp: true, raise: true}
+	result, err := moveIfNeeded(nil, opts, xwindow.New(nil, 1), xrect.New(100, 100, 800, 600), screens, 0, 1)
+	if err != nil || result != moved {
+		t.Errorf("moveIfNeeded in dry run = %v, %v, want moved without error", result, err)
+	}
+}
+
+func TestPercentRect(t *testing.T) {
+	screen := xrect.New(1920, 0, 2000, 1000)
+	tests := []struct {
+		pct  int
+		want xrect.Rect
+	}{
+		{25, xrect.New(2670, 375, 500, 250)},
+		{50, xrect.New(2420, 250, 1000, 500)},
+		{100, xrect.New(1920, 0, 2000, 1000)},
+		{150, xrect.New(1920, 0, 2000, 1000)},
+		{0, xrect.New(2910, 495, 20, 10)},
+		{-10, xrect.New(2910, 495, 20, 10)},
+	}
+	for _, tt := range tests {
+		if got := percentRect(tt.pct, screen); !gotomonitor.SameRect(got, tt.want) {
+			t.Errorf("percentRect(%d) = %v, want %v", tt.pct, got, tt.want)
+		}
+	}
+}
+
+func TestPositionAtPoint(t *testing.T) {
+	screen := xrect.New(1920, 0, 1920, 1080)
+	tests := []struct {
+		name         string
+		px, py       int
+		wantX, wantY int
+	}{
+		{"middle of the screen", 2880, 540, 2480, 240},
+		{"near the left edge", 2000, 540, 1920, 240},
+		{"near the bottom right corner", 3800, 1050, 3040, 480},
+	}
+	for _, tt := range tests {
+		x, y := positionAtPoint(800, 600, tt.px, tt.py, screen)
+		if x != tt.wantX || y != tt.wantY {
+			t.Errorf("%s: positionAtPoint(800, 600, %d, %d) = %d,%d, want %d,%d", tt.name, tt.px, tt.py, x, y, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+func TestFocusMode(t *testing.T) {
+	tests := []struct {
+		flag      string
+		wasActive bool
+		raised    bool
+		want      bool
+		wantErr   bool
+	}{
+		// Default, the active window keeps focus and a background window moved with -window doesn't take it
+		{"", true, false, true, false},
+		{"", false, false, false, false},
+		{"", false, true, true, false},
+		{"true", false, false, true, false},
+		{"TRUE", true, false, true, false},
+		{"false", true, false, false, false},
+		{"false", true, true, false, false},
+		{"yes", false, false, false, true},
+	}
+	for _, tt := range tests {
+		mode, err := parseFocusMode(tt.flag)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFocusMode(%q) error = %v, want error %v", tt.flag, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := mode.focus(tt.wasActive, tt.raised); got != tt.want {
+			t.Errorf("-focus-after-move=%q focus(active %v, raised %v) = %v, want %v", tt.flag, tt.wasActive, tt.raised, got, tt.want)
+		}
+	}
+}
+
+func TestSwapOptions(t *testing.T) {
+	opts := options{
+		gap:           8,
+		raise:         true,
+		warp:          true,
+		focus:         focusAlways,
+		extraStates:   []string{"_NET_WM_STATE_ABOVE", "_NET_WM_STATE_STICKY"},
+		desktopOffset: 1,
+		followDesktop: true,
+		notify:        true,
+		confirmMs:     300,
+	}
+	got := swapOptions(opts)
+	// -swap -and-desktop +1 only sends the active window to the next desktop
+	if got.desktopOffset != 0 || got.followDesktop {
+		t.Errorf("swap partner desktop offset %d, follow desktop %v, want it left on its desktop", got.desktopOffset, got.followDesktop)
+	}
+	if got.raise || got.warp {
+		t.Errorf("swap partner raise %v, warp %v, want neither", got.raise, got.warp)
+	}
+	// -focus-after-move=true focuses the active window, not the partner moved after it
+	if got.focus != focusNever {
+		t.Errorf("swap partner focus mode = %v, want focusNever", got.focus)
+	}
+	// A sticky active window doesn't make the partner sticky
+	if len(got.extraStates) != 0 {
+		t.Errorf("swap partner extra states = %q, want none", got.extraStates)
+	}
+	if got.notify || got.confirmMs != 0 {
+		t.Errorf("swap partner notify %v, confirm %dms, want no second notification or overlay", got.notify, got.confirmMs)
+	}
+	if got.gap != opts.gap {
+		t.Errorf("swap partner gap = %d, want it placed like the active window with %d", got.gap, opts.gap)
+	}
+}
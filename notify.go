@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// How long a notification is shown for, in milliseconds
+const notifyTimeout = 2000
+
+// Summary and body of the notification shown after moving a window to a monitor
+func notificationText(monitorName string, index int) (summary, body string) {
+	summary = fmt.Sprintf("Moved to monitor %d", index)
+	if monitorName != "" {
+		summary = fmt.Sprintf("Moved to %s (monitor %d)", monitorName, index)
+	}
+	return summary, "go-to-monitor"
+}
+
+// Shows a desktop notification through org.freedesktop.Notifications, does nothing if there is no session bus
+func notifyMove(monitorName string, index int) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		debug.Printf("No D-Bus session bus, not notifying: %v", err)
+		return nil
+	}
+
+	summary, body := notificationText(monitorName, index)
+	notifications := conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	call := notifications.Call("org.freedesktop.Notifications.Notify", 0,
+		"go-to-monitor", uint32(0), "", summary, body,
+		[]string{}, map[string]dbus.Variant{}, int32(notifyTimeout))
+	return call.Err
+}
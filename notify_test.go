@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestNotificationText(t *testing.T) {
+	tests := []struct {
+		name        string
+		index       int
+		wantSummary string
+	}{
+		{"DP-2", 1, "Moved to DP-2 (monitor 1)"},
+		{"", 0, "Moved to monitor 0"},
+	}
+	for _, tt := range tests {
+		summary, body := notificationText(tt.name, tt.index)
+		if summary != tt.wantSummary {
+			t.Errorf("notificationText(%q, %d) summary = %q, want %q", tt.name, tt.index, summary, tt.wantSummary)
+		}
+		if body != "go-to-monitor" {
+			t.Errorf("notificationText(%q, %d) body = %q, want %q", tt.name, tt.index, body, "go-to-monitor")
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xrect"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+// Geometry of a RandR CRTC
+func crtcRect(crtc *randr.GetCrtcInfoReply) xrect.Rect {
+	return xrect.New(int(crtc.X), int(crtc.Y), int(crtc.Width), int(crtc.Height))
+}
+
+// Find the screen with the same geometry as rect
+func matchScreen(rect xrect.Rect, screens []xrect.Rect) int {
+	for i, r := range screens {
+		if gotomonitor.SameRect(r, rect) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Index of the screen driven by the RandR primary output, or -1 if no primary output is set
+func primaryScreenIndex(X *xgbutil.XUtil, screens []xrect.Rect) (int, error) {
+	return primaryScreen(screens, func() (*randr.GetCrtcInfoReply, error) { return primaryCrtc(X) })
+}
+
+// Index of the screen showing the CRTC returned by primary, -1 if it returns none
+func primaryScreen(screens []xrect.Rect, primary func() (*randr.GetCrtcInfoReply, error)) (int, error) {
+	crtc, err := primary()
+	if err != nil {
+		return -1, err
+	}
+	if crtc == nil {
+		return -1, nil
+	}
+	return matchScreen(crtcRect(crtc), screens), nil
+}
+
+// CRTC driving the RandR primary output, nil if no primary output is set or it is disabled
+func primaryCrtc(X *xgbutil.XUtil) (*randr.GetCrtcInfoReply, error) {
+	err := randr.Init(X.Conn())
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := randr.GetOutputPrimary(X.Conn(), X.RootWin()).Reply()
+	if err != nil {
+		return nil, err
+	}
+	if primary.Output == 0 {
+		return nil, nil
+	}
+
+	output, err := randr.GetOutputInfo(X.Conn(), primary.Output, xproto.TimeCurrentTime).Reply()
+	if err != nil {
+		return nil, err
+	}
+	if output.Crtc == 0 {
+		// primary output is disabled
+		return nil, nil
+	}
+
+	return randr.GetCrtcInfo(X.Conn(), output.Crtc, xproto.TimeCurrentTime).Reply()
+}
+
+// Geometry of every enabled CRTC, CRTCs without a mode or without outputs are disabled
+func crtcRects(crtcs []*randr.GetCrtcInfoReply) []xrect.Rect {
+	rects := make([]xrect.Rect, 0, len(crtcs))
+	for _, crtc := range crtcs {
+		if crtc.Mode == 0 || crtc.NumOutputs == 0 {
+			continue
+		}
+		rects = append(rects, crtcRect(crtc))
+	}
+	return rects
+}
+
+// Monitor geometry from RandR, which unlike Xinerama stays accurate after hotplugging
+func randrHeads(X *xgbutil.XUtil) ([]xrect.Rect, error) {
+	err := randr.Init(X.Conn())
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := randr.GetScreenResourcesCurrent(X.Conn(), X.RootWin()).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	crtcs := make([]*randr.GetCrtcInfoReply, 0, len(resources.Crtcs))
+	for _, id := range resources.Crtcs {
+		crtc, err := randr.GetCrtcInfo(X.Conn(), id, resources.ConfigTimestamp).Reply()
+		if err != nil {
+			return nil, err
+		}
+		crtcs = append(crtcs, crtc)
+	}
+
+	return crtcRects(crtcs), nil
+}
+
+// A RandR output, Geom is nil if the output is disabled.
+// The physical size is 0 if the output doesn't report one
+type Monitor struct {
+	Name     string
+	Geom     xrect.Rect
+	Primary  bool
+	MmWidth  int
+	MmHeight int
+}
+
+// Every RandR output with the geometry of the CRTC driving it
+func namedMonitors(X *xgbutil.XUtil) ([]Monitor, error) {
+	err := randr.Init(X.Conn())
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := randr.GetScreenResourcesCurrent(X.Conn(), X.RootWin()).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := randr.GetOutputPrimary(X.Conn(), X.RootWin()).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	monitors := make([]Monitor, 0, len(resources.Outputs))
+	for _, id := range resources.Outputs {
+		output, err := randr.GetOutputInfo(X.Conn(), id, resources.ConfigTimestamp).Reply()
+		if err != nil {
+			return nil, err
+		}
+
+		monitor := Monitor{
+			Name:     string(output.Name),
+			Primary:  id == primary.Output,
+			MmWidth:  int(output.MmWidth),
+			MmHeight: int(output.MmHeight),
+		}
+		if output.Crtc != 0 {
+			crtc, err := randr.GetCrtcInfo(X.Conn(), output.Crtc, resources.ConfigTimestamp).Reply()
+			if err != nil {
+				return nil, err
+			}
+			monitor.Geom = crtcRect(crtc)
+		}
+		monitors = append(monitors, monitor)
+	}
+
+	return monitors, nil
+}
+
+// Find an enabled monitor by output name, ignoring case
+func findMonitor(monitors []Monitor, name string) (Monitor, error) {
+	for _, monitor := range monitors {
+		if strings.EqualFold(monitor.Name, name) {
+			if monitor.Geom == nil {
+				return monitor, fmt.Errorf("output %s is disabled", monitor.Name)
+			}
+			return monitor, nil
+		}
+	}
+	return Monitor{}, fmt.Errorf("no output named %s", name)
+}
+
+// Index of the first enabled monitor whose output name matches the regular expression pattern
+func matchMonitor(monitors []Monitor, pattern string) (int, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return -1, fmt.Errorf("invalid output pattern: %v", err)
+	}
+	for i, monitor := range monitors {
+		if monitor.Geom != nil && re.MatchString(monitor.Name) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no enabled output matches %s", pattern)
+}
+
+// The RandR output with geometry screen, ok is false if it can't be found
+func monitorAt(X *xgbutil.XUtil, screen xrect.Rect) (monitor Monitor, ok bool) {
+	monitors, err := namedMonitors(X)
+	if err != nil {
+		return Monitor{}, false
+	}
+	return monitorWithGeometry(monitors, screen)
+}
+
+// The enabled monitor with geometry screen
+func monitorWithGeometry(monitors []Monitor, screen xrect.Rect) (monitor Monitor, ok bool) {
+	for _, monitor := range monitors {
+		if monitor.Geom != nil && gotomonitor.SameRect(monitor.Geom, screen) {
+			return monitor, true
+		}
+	}
+	return Monitor{}, false
+}
+
+// RandR output name of the monitor with geometry screen, or "" if it can't be found
+func monitorName(X *xgbutil.XUtil, screen xrect.Rect) string {
+	monitor, _ := monitorAt(X, screen)
+	return monitor.Name
+}
+
+// Scales geo from src to dst keeping its physical size, so a window is the same number of millimetres wide on
+// a dense monitor as on a sparse one. src and dst are the areas the window is placed relative to, like Scale, and
+// srcMon and dstMon the monitors giving their pixel density. The position is kept proportional.
+// Falls back to scaling by pixels if either monitor doesn't report its physical size
+func physicalScale(geo, src, dst xrect.Rect, srcMon, dstMon Monitor) xrect.Rect {
+	if srcMon.MmWidth <= 0 || srcMon.MmHeight <= 0 || dstMon.MmWidth <= 0 || dstMon.MmHeight <= 0 {
+		return gotomonitor.Scale(geo, src, dst)
+	}
+
+	rgeo := gotomonitor.BuildRelative(geo, src)
+	// Window size in millimetres, then in pixels of the target monitor, as a fraction of the target area
+	width_mm := float64(geo.Width()) * float64(srcMon.MmWidth) / float64(srcMon.Geom.Width())
+	height_mm := float64(geo.Height()) * float64(srcMon.MmHeight) / float64(srcMon.Geom.Height())
+	rgeo.Width = width_mm * float64(dstMon.Geom.Width()) / float64(dstMon.MmWidth) / float64(dst.Width())
+	rgeo.Height = height_mm * float64(dstMon.Geom.Height()) / float64(dstMon.MmHeight) / float64(dst.Height())
+	return gotomonitor.BuildAbsolute(rgeo, dst)
+}
+
+// Scale factor configured for the output driving screen, 1 if there is none
+func monitorScale(X *xgbutil.XUtil, scales map[string]float64, screen xrect.Rect) float64 {
+	scale, ok := scales[monitorName(X, screen)]
+	if !ok {
+		return 1
+	}
+	return scale
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgbutil/xrect"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+// Stands in for the RandR reply for the primary output's CRTC
+func stubPrimary(crtc *randr.GetCrtcInfoReply, err error) func() (*randr.GetCrtcInfoReply, error) {
+	return func() (*randr.GetCrtcInfoReply, error) { return crtc, err }
+}
+
+func TestPrimaryScreen(t *testing.T) {
+	screens := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 2560, 1440)}
+	tests := []struct {
+		name    string
+		primary func() (*randr.GetCrtcInfoReply, error)
+		want    int
+		wantErr bool
+	}{
+		{"primary on the second monitor", stubPrimary(&randr.GetCrtcInfoReply{X: 1920, Y: 0, Width: 2560, Height: 1440}, nil), 1, false},
+		{"primary on the first monitor", stubPrimary(&randr.GetCrtcInfoReply{X: 0, Y: 0, Width: 1920, Height: 1080}, nil), 0, false},
+		{"no primary output", stubPrimary(nil, nil), -1, false},
+		{"primary not among the monitors", stubPrimary(&randr.GetCrtcInfoReply{X: 0, Y: 1080, Width: 1280, Height: 1024}, nil), -1, false},
+		{"RandR error", stubPrimary(nil, errors.New("RandR extension not available")), -1, true},
+	}
+	for _, tt := range tests {
+		got, err := primaryScreen(screens, tt.primary)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: primaryScreen error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: primaryScreen = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCrtcRects(t *testing.T) {
+	crtcs := []*randr.GetCrtcInfoReply{
+		{X: 0, Y: 0, Width: 1920, Height: 1080, Mode: 0x47, NumOutputs: 1},
+		// Disabled, no mode
+		{X: 0, Y: 0, Width: 0, Height: 0, Mode: 0, NumOutputs: 0},
+		{X: 1920, Y: 0, Width: 2560, Height: 1440, Mode: 0x52, NumOutputs: 1},
+		// A mode but nothing connected to it
+		{X: 4480, Y: 0, Width: 1280, Height: 1024, Mode: 0x60, NumOutputs: 0},
+	}
+	want := []xrect.Rect{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 2560, 1440)}
+	got := crtcRects(crtcs)
+	if len(got) != len(want) {
+		t.Fatalf("crtcRects = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !gotomonitor.SameRect(got[i], want[i]) {
+			t.Errorf("crtcRects[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFindMonitor(t *testing.T) {
+	monitors := []Monitor{
+		{Name: "eDP-1", Geom: xrect.New(0, 0, 1920, 1080), Primary: true},
+		{Name: "HDMI-1"},
+		{Name: "DP-2", Geom: xrect.New(1920, 0, 2560, 1440)},
+	}
+	tests := []struct {
+		name     string
+		wantGeom xrect.Rect
+		wantErr  bool
+	}{
+		{"DP-2", monitors[2].Geom, false},
+		{"dp-2", monitors[2].Geom, false},
+		{"EDP-1", monitors[0].Geom, false},
+		// Connected but disabled
+		{"HDMI-1", nil, true},
+		{"DP-3", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := findMonitor(monitors, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("findMonitor(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+		} else if err == nil && !gotomonitor.SameRect(got.Geom, tt.wantGeom) {
+			t.Errorf("findMonitor(%q) = %v, want %v", tt.name, got.Geom, tt.wantGeom)
+		}
+	}
+}
+
+func TestMonitorWithGeometry(t *testing.T) {
+	monitors := []Monitor{
+		{Name: "eDP-1", Geom: xrect.New(0, 0, 1920, 1080)},
+		{Name: "HDMI-1"},
+		{Name: "DP-2", Geom: xrect.New(1920, 0, 2560, 1440)},
+	}
+	if got, ok := monitorWithGeometry(monitors, xrect.New(1920, 0, 2560, 1440)); !ok || got.Name != "DP-2" {
+		t.Errorf("monitorWithGeometry(DP-2's geometry) = %q %v, want DP-2", got.Name, ok)
+	}
+	// e.g. a Xinerama head RandR doesn't know about
+	if got, ok := monitorWithGeometry(monitors, xrect.New(0, 0, 1280, 1024)); ok {
+		t.Errorf("monitorWithGeometry(unknown geometry) = %q, want none", got.Name)
+	}
+}
+
+func TestPhysicalScale(t *testing.T) {
+	// Same resolution, the laptop panel is half the size of the desktop monitor
+	desktop := Monitor{Name: "DP-1", Geom: xrect.New(0, 0, 1920, 1080), MmWidth: 600, MmHeight: 340}
+	laptop := Monitor{Name: "eDP-1", Geom: xrect.New(1920, 0, 1920, 1080), MmWidth: 300, MmHeight: 170}
+	unknown := Monitor{Name: "HDMI-1", Geom: xrect.New(1920, 0, 1920, 1080)}
+	geo := xrect.New(480, 270, 960, 540)
+
+	tests := []struct {
+		name     string
+		geo      xrect.Rect
+		src, dst Monitor
+		want     xrect.Rect
+	}{
+		{"onto the smaller monitor", geo, desktop, laptop, xrect.New(2400, 270, 1920, 1080)},
+		{"onto the larger monitor", xrect.New(2400, 270, 960, 540), laptop, desktop, xrect.New(480, 270, 480, 270)},
+		{"no physical size", geo, desktop, unknown, gotomonitor.Scale(geo, desktop.Geom, unknown.Geom)},
+	}
+	for _, tt := range tests {
+		got := physicalScale(tt.geo, tt.src.Geom, tt.dst.Geom, tt.src, tt.dst)
+		if !gotomonitor.SameRect(got, tt.want) {
+			t.Errorf("%s: physicalScale(%v) = %v, want %v", tt.name, tt.geo, got, tt.want)
+		}
+	}
+}
+
+func TestMatchMonitor(t *testing.T) {
+	monitors := []Monitor{
+		{Name: "eDP-1", Geom: xrect.New(0, 0, 1920, 1080)},
+		{Name: "DP-1"},
+		{Name: "DP-2", Geom: xrect.New(1920, 0, 2560, 1440)},
+		{Name: "DP-3", Geom: xrect.New(4480, 0, 2560, 1440)},
+	}
+	tests := []struct {
+		pattern string
+		want    int
+		wantErr bool
+	}{
+		// DP-1 is disabled, so the first DisplayPort output that can be moved to
+		{"^DP-", 2, false},
+		{"eDP", 0, false},
+		{"3$", 3, false},
+		{"^HDMI-", -1, true},
+		{"DP-(", -1, true},
+	}
+	for _, tt := range tests {
+		got, err := matchMonitor(monitors, tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("matchMonitor(%q) error = %v, want error %v", tt.pattern, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("matchMonitor(%q) = %d, want %d", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestPhysicalScaleWorkArea(t *testing.T) {
+	desktop := Monitor{Name: "DP-1", Geom: xrect.New(0, 0, 1920, 1080), MmWidth: 600, MmHeight: 340}
+	laptop := Monitor{Name: "eDP-1", Geom: xrect.New(1920, 0, 1920, 1080), MmWidth: 600, MmHeight: 340}
+	// A 40px panel along the top of the laptop, the window keeps its size and is placed within the work area
+	work_area := xrect.New(1920, 40, 1920, 1040)
+	got := physicalScale(xrect.New(0, 0, 960, 540), desktop.Geom, work_area, desktop, laptop)
+	if want := xrect.New(1920, 40, 960, 540); !gotomonitor.SameRect(got, want) {
+		t.Errorf("physicalScale onto the work area = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xrect"
+	"github.com/BurntSushi/xgbutil/xwindow"
+
+	"danielcranford/go-to-monitor/gotomonitor"
+)
+
+// Number of moves remembered per window
+const historyCap = 10
+
+type Geometry struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func geometryOf(r xrect.Rect) Geometry {
+	return Geometry{X: r.X(), Y: r.Y(), Width: r.Width(), Height: r.Height()}
+}
+
+func (g Geometry) Rect() xrect.Rect {
+	return xrect.New(g.X, g.Y, g.Width, g.Height)
+}
+
+// Where a window was before it was moved
+type UndoState struct {
+	Window   xproto.Window `json:"window"`
+	Geometry Geometry      `json:"geometry"`
+	States   []string      `json:"states"`
+}
+
+// The last few moves of each window, oldest first
+type MoveHistory struct {
+	Windows map[xproto.Window][]UndoState `json:"windows"`
+}
+
+// Records a move, dropping the window's oldest move once it has more than historyCap
+func pushHistory(h *MoveHistory, state UndoState) {
+	if h.Windows == nil {
+		h.Windows = make(map[xproto.Window][]UndoState)
+	}
+	moves := append(h.Windows[state.Window], state)
+	if len(moves) > historyCap {
+		moves = moves[len(moves)-historyCap:]
+	}
+	h.Windows[state.Window] = moves
+}
+
+// Removes and returns a window's most recent move, ok is false if there is nothing to undo
+func popHistory(h *MoveHistory, win xproto.Window) (state UndoState, ok bool) {
+	moves := h.Windows[win]
+	if len(moves) == 0 {
+		return state, false
+	}
+	state = moves[len(moves)-1]
+	if len(moves) == 1 {
+		delete(h.Windows, win)
+	} else {
+		h.Windows[win] = moves[:len(moves)-1]
+	}
+	return state, true
+}
+
+// Forgets windows that no longer exist
+func pruneHistory(X *xgbutil.XUtil, h *MoveHistory) {
+	for win := range h.Windows {
+		_, err := xwindow.RawGeometry(X, xproto.Drawable(win))
+		if err != nil {
+			delete(h.Windows, win)
+		}
+	}
+}
+
+// Path of a file in $XDG_RUNTIME_DIR, so it doesn't outlive the session. Without one the file goes in a
+// go-to-monitor directory in the user's cache directory, never a shared directory such as /tmp where another user
+// could plant a symlink in its place
+func runtimePath(name string) (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("no $XDG_RUNTIME_DIR or cache directory: %v", err)
+		}
+		dir = filepath.Join(cache, "go-to-monitor")
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// File moves are recorded in
+func undoStatePath() (string, error) {
+	return runtimePath("go-to-monitor.json")
+}
+
+// Writes the move history to a temporary file and renames it over path, so a half written file is never read
+// and a symlink at path is replaced rather than followed
+func saveUndoState(path string, h MoveHistory) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	// CreateTemp makes the file readable by the user only
+	f, err := os.CreateTemp(filepath.Dir(path), ".go-to-monitor-*.json")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Reads the move history, a missing file is an empty history
+func loadUndoState(path string) (MoveHistory, error) {
+	var h MoveHistory
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	err = json.Unmarshal(data, &h)
+	if err != nil {
+		return MoveHistory{}, fmt.Errorf("corrupt move history %s: %v", path, err)
+	}
+	return h, nil
+}
+
+// Records where a window is before moving it so the move can be undone
+func undoStateFor(win *xwindow.Window, geo xrect.Rect) UndoState {
+	// A window without _NET_WM_STATE has no states set
+	state, _ := ewmh.WmStateGet(win.X, win.Id)
+	return UndoState{
+		Window:   win.Id,
+		Geometry: geometryOf(geo),
+		States:   gotomonitor.StatesBlockingMove(state),
+	}
+}
+
+// Adds a move to the history file
+func recordMove(X *xgbutil.XUtil, state UndoState) error {
+	path, err := undoStatePath()
+	if err != nil {
+		return err
+	}
+	h, err := loadUndoState(path)
+	if err != nil {
+		// Start over rather than never recording anything again
+		log.Printf("Warning: %v, discarding it", err)
+	}
+	pruneHistory(X, &h)
+	pushHistory(&h, state)
+	return saveUndoState(path, h)
+}
+
+// Puts a window back where it was before its last move, with dryRun only logs where it would go
+func undoLastMove(X *xgbutil.XUtil, win *xwindow.Window, dryRun bool) error {
+	path, err := undoStatePath()
+	if err != nil {
+		return err
+	}
+	h, err := loadUndoState(path)
+	if err != nil {
+		return err
+	}
+	pruneHistory(X, &h)
+
+	err = undoMove(&h, win.Id, dryRun, func(state UndoState) error {
+		return gotomonitor.MoveWindowWithStates(win, state.Geometry.Rect(), state.States)
+	})
+	if err != nil || dryRun {
+		// A dry run leaves the move to be undone for real later
+		return err
+	}
+
+	return saveUndoState(path, h)
+}
+
+// Takes win's last move off h and undoes it with move, or only logs it for a dry run
+func undoMove(h *MoveHistory, win xproto.Window, dryRun bool, move func(UndoState) error) error {
+	state, ok := popHistory(h, win)
+	if !ok {
+		return fmt.Errorf("no move to undo for window %d", win)
+	}
+
+	if dryRun {
+		log.Printf("Would move window %d back to %v, restoring states %v", win, state.Geometry.Rect(), state.States)
+		return nil
+	}
+	return move(state)
+}
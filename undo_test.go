@@ -0,0 +1,186 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUndoStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-to-monitor.json")
+	var h MoveHistory
+	pushHistory(&h, UndoState{
+		Window:   0x1c00007,
+		Geometry: Geometry{X: 10, Y: 20, Width: 800, Height: 600},
+		States:   []string{"_NET_WM_STATE_FULLSCREEN"},
+	})
+
+	err := saveUndoState(path, h)
+	if err != nil {
+		t.Fatalf("saveUndoState error: %v", err)
+	}
+	loaded, err := loadUndoState(path)
+	if err != nil {
+		t.Fatalf("loadUndoState error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, h) {
+		t.Errorf("loadUndoState = %+v, want %+v", loaded, h)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("undo state file has permissions %v, want 0600", perm)
+	}
+}
+
+func TestLoadUndoStateMissing(t *testing.T) {
+	h, err := loadUndoState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("loadUndoState of a missing file error: %v", err)
+	}
+	if len(h.Windows) != 0 {
+		t.Errorf("loadUndoState of a missing file = %+v, want an empty history", h)
+	}
+}
+
+func TestLoadUndoStateCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-to-monitor.json")
+	err := os.WriteFile(path, []byte("{not json"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadUndoState(path)
+	if err == nil {
+		t.Errorf("loadUndoState accepted a corrupt file")
+	}
+}
+
+func TestSaveUndoStateReplacesSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "victim")
+	err := os.WriteFile(target, []byte("precious"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "go-to-monitor.json")
+	err = os.Symlink(target, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = saveUndoState(path, MoveHistory{})
+	if err != nil {
+		t.Fatalf("saveUndoState error: %v", err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "precious" {
+		t.Errorf("saveUndoState wrote through a symlink, target now holds %q", data)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("saveUndoState left the symlink in place")
+	}
+}
+
+func TestRuntimePath(t *testing.T) {
+	runtime_dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtime_dir)
+	path, err := runtimePath("go-to-monitor.json")
+	if err != nil {
+		t.Fatalf("runtimePath error: %v", err)
+	}
+	if want := filepath.Join(runtime_dir, "go-to-monitor.json"); path != want {
+		t.Errorf("runtimePath with $XDG_RUNTIME_DIR = %q, want %q", path, want)
+	}
+
+	// Without a runtime directory the file goes in a private directory, not a shared one
+	cache_dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("XDG_CACHE_HOME", cache_dir)
+	path, err = runtimePath("go-to-monitor.json")
+	if err != nil {
+		t.Fatalf("runtimePath error: %v", err)
+	}
+	dir := filepath.Join(cache_dir, "go-to-monitor")
+	if want := filepath.Join(dir, "go-to-monitor.json"); path != want {
+		t.Errorf("runtimePath without $XDG_RUNTIME_DIR = %q, want %q", path, want)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("runtimePath didn't create its directory: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("runtimePath created its directory with permissions %v, want 0700", perm)
+	}
+}
+
+func TestHistoryRing(t *testing.T) {
+	var h MoveHistory
+	for i := 0; i < historyCap+3; i++ {
+		pushHistory(&h, UndoState{Window: 0x1c00007, Geometry: Geometry{X: i}})
+	}
+	pushHistory(&h, UndoState{Window: 0x3a00004, Geometry: Geometry{X: 100}})
+
+	if got := len(h.Windows[0x1c00007]); got != historyCap {
+		t.Fatalf("history holds %d moves, want the cap %d", got, historyCap)
+	}
+	// Newest first, the oldest moves were dropped
+	for want := historyCap + 2; want >= 3; want-- {
+		state, ok := popHistory(&h, 0x1c00007)
+		if !ok || state.Geometry.X != want {
+			t.Fatalf("popHistory = %+v %v, want move %d", state, ok, want)
+		}
+	}
+	if _, ok := popHistory(&h, 0x1c00007); ok {
+		t.Errorf("popHistory returned a move after the history was emptied")
+	}
+
+	// Other windows keep their own history
+	state, ok := popHistory(&h, 0x3a00004)
+	if !ok || state.Geometry.X != 100 {
+		t.Errorf("popHistory of another window = %+v %v, want its move", state, ok)
+	}
+}
+
+func TestPopEmptyHistory(t *testing.T) {
+	var h MoveHistory
+	state, ok := popHistory(&h, 0x1c00007)
+	if ok || !reflect.DeepEqual(state, UndoState{}) {
+		t.Errorf("popHistory of an empty history = %+v %v, want nothing", state, ok)
+	}
+}
+
+func TestUndoMoveDryRun(t *testing.T) {
+	var h MoveHistory
+	pushHistory(&h, UndoState{Window: 0x1c00007, Geometry: Geometry{X: 100, Y: 50, Width: 800, Height: 600}})
+
+	var undone []UndoState
+	move := func(state UndoState) error {
+		undone = append(undone, state)
+		return nil
+	}
+	err := undoMove(&h, 0x1c00007, true, move)
+	if err != nil || len(undone) != 0 {
+		t.Errorf("undoMove dry run = %v, moved %+v, want no move", err, undone)
+	}
+
+	var h2 MoveHistory
+	pushHistory(&h2, UndoState{Window: 0x1c00007, Geometry: Geometry{X: 100, Y: 50, Width: 800, Height: 600}})
+	err = undoMove(&h2, 0x1c00007, false, move)
+	if err != nil || len(undone) != 1 || undone[0].Geometry.X != 100 {
+		t.Errorf("undoMove = %v, moved %+v, want the recorded move undone", err, undone)
+	}
+	if err := undoMove(&h2, 0x1c00007, false, move); err == nil {
+		t.Errorf("undoMove with nothing left to undo succeeded")
+	}
+}